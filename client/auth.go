@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the bearer tokens used to authenticate API requests.
+// Token is called for every request, so implementations should cache the token until it expires.
+// If the TokenSource also implements the Invalidate method, it is called when the API responds
+// with 401 Unauthorized, and the request is sent again once with a new token.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions as a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// NewRefreshingTokenSource returns a TokenSource which caches the token returned by fetch
+// and fetches a new one shortly before it expires or when the API rejects it.
+// A zero expiry means the token does not expire.
+func NewRefreshingTokenSource(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) TokenSource {
+	return &refreshingTokenSource{fetch: fetch}
+}
+
+// tokenExpiryDelta is how long before its expiry a token is refreshed.
+const tokenExpiryDelta = 10 * time.Second
+
+type refreshingTokenSource struct {
+	expiry time.Time
+	fetch  func(ctx context.Context) (string, time.Time, error)
+	token  string
+	mu     sync.Mutex
+}
+
+// Token returns the cached token, fetching a new one if needed.
+func (ts *refreshingTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && (ts.expiry.IsZero() || time.Until(ts.expiry) > tokenExpiryDelta) {
+		return ts.token, nil
+	}
+
+	token, expiry, err := ts.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch token: %w", err)
+	}
+	ts.token = token
+	ts.expiry = expiry
+	return token, nil
+}
+
+// Invalidate discards the cached token.
+func (ts *refreshingTokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.token = ""
+}
+
+type invalidator interface {
+	Invalidate()
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+// authentication holds the credentials and headers added to every API request.
+type authentication struct {
+	basic       *basicAuth
+	tokenSource TokenSource
+	headers     http.Header
+}
+
+func (client *NginxClient) authOptions() *authentication {
+	if client.auth == nil {
+		client.auth = &authentication{headers: http.Header{}}
+	}
+	return client.auth
+}
+
+// WithBasicAuth sets the username and password used to authenticate API requests with HTTP basic authentication.
+func WithBasicAuth(username, password string) Option {
+	return func(o *NginxClient) {
+		o.authOptions().basic = &basicAuth{username: username, password: password}
+	}
+}
+
+// WithBearerToken sets the static bearer token used to authenticate API requests.
+func WithBearerToken(token string) Option {
+	return WithTokenSource(TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	}))
+}
+
+// WithTokenSource sets the source of the bearer tokens used to authenticate API requests.
+func WithTokenSource(tokenSource TokenSource) Option {
+	return func(o *NginxClient) {
+		o.authOptions().tokenSource = tokenSource
+	}
+}
+
+// WithHeaders sets headers added to every API request. Multiple calls add the headers together.
+func WithHeaders(headers http.Header) Option {
+	return func(o *NginxClient) {
+		auth := o.authOptions()
+		for name, values := range headers {
+			auth.headers[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to access the API, for example to present a client certificate
+// or to trust a custom CA. The transport of the HTTP client must be an *http.Transport.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *NginxClient) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+func (a *authentication) validate() error {
+	if a.basic != nil && a.tokenSource != nil {
+		return fmt.Errorf("basic authentication with a bearer token: %w", ErrNotSupported)
+	}
+	return nil
+}
+
+func (a *authentication) apply(req *http.Request) error {
+	for name, values := range a.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+
+	if a.basic != nil {
+		req.SetBasicAuth(a.basic.username, a.basic.password)
+	}
+
+	if a.tokenSource != nil {
+		token, err := a.tokenSource.Token(req.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
+
+// authenticate is the middleware that adds the credentials and headers to API requests.
+func (a *authentication) authenticate(next RequestHandler) RequestHandler {
+	return func(op Operation, req *http.Request) (*http.Response, error) {
+		if err := a.apply(req); err != nil {
+			return nil, err
+		}
+
+		resp, err := next(op, req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		tokens, ok := a.tokenSource.(invalidator)
+		if !ok || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, nil //nolint:nilerr // the response of the first attempt is returned.
+			}
+			retry.Body = body
+		}
+
+		resp.Body.Close()
+		tokens.Invalidate()
+		if err := a.apply(retry); err != nil {
+			return nil, err
+		}
+		return next(op, retry)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientWithAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		authorized func(r *http.Request) bool
+		opts       []Option
+	}{
+		{
+			name: "basic auth",
+			authorized: func(r *http.Request) bool {
+				username, password, ok := r.BasicAuth()
+				return ok && username == "user" && password == "pass"
+			},
+			opts: []Option{WithBasicAuth("user", "pass")},
+		},
+		{
+			name: "bearer token",
+			authorized: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer token"
+			},
+			opts: []Option{WithBearerToken("token")},
+		},
+		{
+			name: "custom headers",
+			authorized: func(r *http.Request) bool {
+				return r.Header.Get("X-Api-Key") == "key" && r.Header.Get("X-Tenant") == "tenant"
+			},
+			opts: []Option{
+				WithHeaders(http.Header{"x-api-key": []string{"key"}}),
+				WithHeaders(http.Header{"X-Tenant": []string{"tenant"}}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.authorized(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch r.RequestURI {
+				case "/":
+					_, _ = w.Write([]byte(`[4, 5, 6, 7, 8]`))
+				default:
+					_, _ = w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			opts := append([]Option{WithMaxAPIVersion(), WithCheckAPI()}, tt.opts...)
+			c, err := NewNginxClient(ts.URL, opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.apiVersion != 8 {
+				t.Fatalf("expected the API version to be negotiated with authentication, got %v", c.apiVersion)
+			}
+
+			_, err = c.GetNginxInfo(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientWithRefreshingTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var validToken atomic.Pointer[string]
+	token1, token2 := "token-1", "token-2"
+	validToken.Store(&token1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+*validToken.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	var fetches atomic.Int32
+	tokenSource := NewRefreshingTokenSource(func(context.Context) (string, time.Time, error) {
+		if fetches.Add(1) == 1 {
+			return "token-1", time.Now().Add(time.Hour), nil
+		}
+		return "token-2", time.Now().Add(time.Hour), nil
+	})
+
+	c, err := NewNginxClient(ts.URL, WithTokenSource(tokenSource))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	for range 2 {
+		if err := c.ModifyKeyValPair(ctx, "zone", "key", "val"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("expected the token to be cached, got %v fetches", fetches.Load())
+	}
+
+	validToken.Store(&token2)
+	if err := c.ModifyKeyValPair(ctx, "zone", "key", "val"); err != nil {
+		t.Fatalf("expected the token to be refreshed, got error: %v", err)
+	}
+	if fetches.Load() != 2 {
+		t.Fatalf("expected the token to be refreshed once, got %v fetches", fetches.Load())
+	}
+}
+
+func TestClientWithTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`[4, 5, 6, 7, 8, 9]`))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ts.Certificate())
+	clientCert := ts.TLS.Certificates[0]
+
+	_, err := NewNginxClient(ts.URL, WithCheckAPI())
+	if err == nil {
+		t.Fatal("expected error for an unknown CA, but got nil")
+	}
+
+	_, err = NewNginxClient(ts.URL, WithCheckAPI(), WithTLSConfig(&tls.Config{
+		RootCAs:      caPool,
+		Certificates: []tls.Certificate{clientCert},
+		MinVersion:   tls.VersionTLS12,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClientWithConflictingAuthentication(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewNginxClient("http://api-url", WithBasicAuth("user", "pass"), WithBearerToken("token"))
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultBatchConcurrency is the number of upstreams updated concurrently by UpdateUpstreams if the concurrency is not set.
+const defaultBatchConcurrency = 4
+
+// UpstreamUpdate is the desired servers of an HTTP or stream upstream, for UpdateUpstreams.
+type UpstreamUpdate struct {
+	Upstream string
+	// HTTPServers are the servers of the HTTP upstream. They are ignored for a stream upstream.
+	HTTPServers []UpstreamServer
+	// StreamServers are the servers of the stream upstream. They are ignored for an HTTP upstream.
+	StreamServers []StreamUpstreamServer
+	Stream        bool
+}
+
+// BatchOptions configures UpdateUpstreams.
+type BatchOptions struct {
+	// Concurrency is the maximum number of upstreams updated at the same time. It defaults to 4.
+	// The changes to the servers of each upstream are made with the update concurrency of the client.
+	Concurrency int
+	// Atomic makes the update of each upstream all or nothing, like UpdateHTTPServersAtomically.
+	Atomic bool
+}
+
+// UpstreamUpdateResult is the result of the update of an upstream by UpdateUpstreams.
+type UpstreamUpdateResult struct {
+	// Err is the error of the update, or nil if all the changes were made.
+	Err      error
+	Upstream string
+	// Added, Deleted and Updated are the servers of the HTTP upstream successfully changed.
+	Added   []UpstreamServer
+	Deleted []UpstreamServer
+	Updated []UpstreamServer
+	// StreamAdded, StreamDeleted and StreamUpdated are the servers of the stream upstream successfully changed.
+	StreamAdded   []StreamUpstreamServer
+	StreamDeleted []StreamUpstreamServer
+	StreamUpdated []StreamUpstreamServer
+	// Duration is how long the update of the upstream took.
+	Duration time.Duration
+	Stream   bool
+}
+
+// BatchSummary summarizes the results of UpdateUpstreams.
+type BatchSummary struct {
+	Upstreams int
+	Succeeded int
+	Failed    int
+	// Added, Deleted and Updated are the numbers of servers changed in all the upstreams.
+	Added   int
+	Deleted int
+	Updated int
+	// Duration is how long the whole batch took.
+	Duration time.Duration
+}
+
+// BatchResult is the result of UpdateUpstreams.
+type BatchResult struct {
+	// Results are the results of the updates, in the order of the updates.
+	Results []UpstreamUpdateResult
+	Summary BatchSummary
+}
+
+// UpdateUpstreams updates the servers of many HTTP and stream upstreams, like UpdateHTTPServers and
+// UpdateStreamServers, updating at most the concurrency of the options upstreams at the same time.
+// A failed upstream doesn't stop the updates of the others. The error joins the errors of all the failed upstreams,
+// which are also reported in their results. If the context is canceled, the remaining upstreams fail with its error.
+func (client *NginxClient) UpdateUpstreams(ctx context.Context, updates []UpstreamUpdate, opts BatchOptions) (*BatchResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	start := time.Now()
+	results := make([]UpstreamUpdateResult, len(updates))
+	var group errgroup.Group
+	group.SetLimit(concurrency)
+	for i, update := range updates {
+		group.Go(func() error {
+			results[i] = client.updateUpstream(ctx, update, opts.Atomic)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	batch := &BatchResult{
+		Results: results,
+		Summary: BatchSummary{Upstreams: len(results), Duration: time.Since(start)},
+	}
+	var err error
+	for _, result := range results {
+		if result.Err != nil {
+			batch.Summary.Failed++
+			err = errors.Join(err, result.Err)
+		} else {
+			batch.Summary.Succeeded++
+		}
+		batch.Summary.Added += len(result.Added) + len(result.StreamAdded)
+		batch.Summary.Deleted += len(result.Deleted) + len(result.StreamDeleted)
+		batch.Summary.Updated += len(result.Updated) + len(result.StreamUpdated)
+	}
+	return batch, err
+}
+
+func (client *NginxClient) updateUpstream(ctx context.Context, update UpstreamUpdate, atomic bool) UpstreamUpdateResult {
+	result := UpstreamUpdateResult{Upstream: update.Upstream, Stream: update.Stream}
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		result.Err = fmt.Errorf("failed to update servers of %v upstream: %w", update.Upstream, err)
+		return result
+	}
+
+	switch {
+	case update.Stream && atomic:
+		result.StreamAdded, result.StreamDeleted, result.StreamUpdated, result.Err = client.UpdateStreamServersAtomically(ctx, update.Upstream, update.StreamServers)
+	case update.Stream:
+		result.StreamAdded, result.StreamDeleted, result.StreamUpdated, result.Err = client.UpdateStreamServers(ctx, update.Upstream, update.StreamServers)
+	case atomic:
+		result.Added, result.Deleted, result.Updated, result.Err = client.UpdateHTTPServersAtomically(ctx, update.Upstream, update.HTTPServers)
+	default:
+		result.Added, result.Deleted, result.Updated, result.Err = client.UpdateHTTPServers(ctx, update.Upstream, update.HTTPServers)
+	}
+	result.Duration = time.Since(start)
+	return result
+}
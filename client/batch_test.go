@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateUpstreams(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			return
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/missing/") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"upstream not found","code":"UpstreamNotFound"}}`))
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(`[{"id":1,"server":"10.0.0.1:80"}]`))
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updates := []UpstreamUpdate{
+		{Upstream: "a", HTTPServers: []UpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.2:80"}}},
+		{Upstream: "b", HTTPServers: []UpstreamServer{{Server: "10.0.0.3:80"}}},
+		{Upstream: "c", Stream: true, StreamServers: []StreamUpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.4:80"}}},
+		{Upstream: "missing", HTTPServers: []UpstreamServer{{Server: "10.0.0.1:80"}}},
+	}
+	batch, err := c.UpdateUpstreams(context.Background(), updates, BatchOptions{Concurrency: 2})
+	if !errors.Is(err, ErrUpstreamNotFound) || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected the error of the missing upstream, got %v", err)
+	}
+
+	expectedSummary := BatchSummary{Upstreams: 4, Succeeded: 3, Failed: 1, Added: 3, Deleted: 1}
+	summary := batch.Summary
+	summary.Duration = 0
+	if summary != expectedSummary {
+		t.Fatalf("expected summary %+v, got %+v", expectedSummary, summary)
+	}
+
+	for i, result := range batch.Results {
+		if result.Upstream != updates[i].Upstream || result.Stream != updates[i].Stream || result.Duration <= 0 {
+			t.Fatalf("unexpected result %+v for update %+v", result, updates[i])
+		}
+	}
+	if len(batch.Results[0].Added) != 1 || len(batch.Results[1].Deleted) != 1 || len(batch.Results[2].StreamAdded) != 1 {
+		t.Fatalf("unexpected results: %+v", batch.Results)
+	}
+	if batch.Results[3].Err == nil {
+		t.Fatal("expected the missing upstream to fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxInFlight != 2 {
+		t.Fatalf("expected 2 upstreams updated concurrently, got %v", maxInFlight)
+	}
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrCanaryRolledBack is wrapped by the CanaryError returned when a canary release is rolled back.
+var ErrCanaryRolledBack = errors.New("canary rolled back")
+
+// ErrInvalidCanaryStep is returned when a step of a canary release is not a percentage between 1 and 100.
+var ErrInvalidCanaryStep = errors.New("invalid canary step")
+
+// CanaryRelease configures the shifting of the traffic of an HTTP upstream from stable servers to canary servers.
+type CanaryRelease struct {
+	// Progress, if not nil, is called after every step is observed.
+	Progress func(CanaryStep)
+	Upstream string
+	// Stable and Canary are the addresses of the stable and canary servers of the upstream.
+	Stable []string
+	Canary []string
+	// Steps are the percentages, from 1 to 100, of the traffic sent to the canary servers at every step.
+	// At 100, the stable servers are set down, as NGINX doesn't accept a weight of zero.
+	Steps []int
+	// Interval is how long the canary servers are observed after every step.
+	Interval time.Duration
+	// MaxErrorRate is the maximum fraction, from 0 to 1, of 5xx responses of the canary servers during a step.
+	// Zero disables the check.
+	MaxErrorRate float64
+	// MaxResponseTime is the maximum average response time of the canary servers. Zero disables the check.
+	MaxResponseTime time.Duration
+	// MinRequests is the minimum number of responses of the canary servers during a step for the error rate to be checked.
+	MinRequests uint64
+}
+
+// CanaryStep describes a step of a canary release, as observed at the end of its interval.
+type CanaryStep struct {
+	// Step is the index of the step in the steps of the release.
+	Step int
+	// Percent is the percentage of the traffic sent to the canary servers.
+	Percent int
+	// Responses and Responses5xx are the numbers of responses and 5xx responses of the canary servers during the step.
+	Responses    uint64
+	Responses5xx uint64
+	// ErrorRate is the fraction of 5xx responses of the canary servers during the step.
+	ErrorRate float64
+	// ResponseTime is the average response time of the canary servers, weighted by their responses during the step.
+	ResponseTime time.Duration
+}
+
+// CanaryError is returned when a canary release is rolled back, because a threshold was breached,
+// a step failed or the context was canceled. The servers are restored to their parameters before the release.
+type CanaryError struct {
+	// Err is the error which failed the release, or nil if a threshold was breached.
+	Err error
+	// RollbackErr is the error of the rollback, or nil if all the servers were restored.
+	RollbackErr error
+	// Reason describes the breached threshold.
+	Reason string
+	// Step is the last observed step.
+	Step CanaryStep
+}
+
+func (e *CanaryError) Error() string {
+	msg := fmt.Sprintf("%v at %v%%", ErrCanaryRolledBack, e.Step.Percent)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(", failed to restore the servers: %v", e.RollbackErr)
+	}
+	return msg
+}
+
+// Unwrap returns ErrCanaryRolledBack and the errors of the release and of the rollback, if any.
+func (e *CanaryError) Unwrap() []error {
+	errs := []error{ErrCanaryRolledBack}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.RollbackErr != nil {
+		errs = append(errs, e.RollbackErr)
+	}
+	return errs
+}
+
+// RunCanary shifts the traffic of the upstream from the stable servers to the canary servers, step by step,
+// by updating the weights of the servers with UpdateHTTPServer. After every step, it waits for the interval of the release
+// and checks the 5xx responses and the response time of the canary peers, as reported by GetUpstream.
+// The peers of a canary server with resolve or service are the peers of the servers resolved from it.
+// If a threshold is breached, a step fails or the context is canceled, the servers are restored to their parameters
+// before the release and a *CanaryError is returned. Once all the steps succeed, the servers keep the weights of the last step.
+// Canary servers which are down before the release are set up by the first step.
+func (client *NginxClient) RunCanary(ctx context.Context, canary CanaryRelease) error {
+	ctx = withOperation(ctx, Operation{Name: "RunCanary", Upstream: canary.Upstream})
+	if len(canary.Stable) == 0 || len(canary.Canary) == 0 || len(canary.Steps) == 0 {
+		return fmt.Errorf("failed to run canary of %v upstream: stable and canary servers and steps: %w", canary.Upstream, ErrParameterRequired)
+	}
+	for _, percent := range canary.Steps {
+		if percent < 1 || percent > 100 {
+			return fmt.Errorf("failed to run canary of %v upstream: %w: %v", canary.Upstream, ErrInvalidCanaryStep, percent)
+		}
+	}
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to run canary of %v upstream: %w", canary.Upstream, err)
+	}
+
+	servers, err := client.GetHTTPServers(ctx, canary.Upstream)
+	if err != nil {
+		return fmt.Errorf("failed to run canary of %v upstream: %w", canary.Upstream, err)
+	}
+	stable, err := selectServers(servers, canary.Stable)
+	if err != nil {
+		return fmt.Errorf("failed to run canary of %v upstream: %w", canary.Upstream, err)
+	}
+	canaries, err := selectServers(servers, canary.Canary)
+	if err != nil {
+		return fmt.Errorf("failed to run canary of %v upstream: %w", canary.Upstream, err)
+	}
+
+	tx := &transaction{}
+	for _, server := range slices.Concat(stable, canaries) {
+		if server.Weight == nil {
+			server.Weight = &defaultWeight
+		}
+		if server.Down == nil {
+			server.Down = &defaultDown
+		}
+		tx.record(func(ctx context.Context) error {
+			return client.UpdateHTTPServer(ctx, canary.Upstream, server)
+		})
+	}
+	rollback := func(step CanaryStep, reason string, err error) error {
+		canaryErr := &CanaryError{Step: step, Reason: reason, Err: err, RollbackErr: client.rollback(ctx, tx)}
+		return fmt.Errorf("failed to run canary of %v upstream: %w", canary.Upstream, canaryErr)
+	}
+
+	for i, percent := range canary.Steps {
+		step := CanaryStep{Step: i, Percent: percent}
+		before, err := client.canaryPeers(ctx, canary.Upstream, canaries)
+		if err != nil {
+			return rollback(step, "", err)
+		}
+		err = client.setCanaryWeights(ctx, canary.Upstream, stable, canaries, percent)
+		if err != nil {
+			return rollback(step, "", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return rollback(step, "", ctx.Err())
+		case <-time.After(canary.Interval):
+		}
+
+		after, err := client.canaryPeers(ctx, canary.Upstream, canaries)
+		if err != nil {
+			return rollback(step, "", err)
+		}
+		step.observe(before, after)
+		if canary.Progress != nil {
+			canary.Progress(step)
+		}
+		if reason := canary.breach(step); reason != "" {
+			return rollback(step, reason, nil)
+		}
+	}
+	return nil
+}
+
+// selectServers returns the servers with the addresses, or an error wrapping ErrServerNotFound if one is missing.
+func selectServers(servers []UpstreamServer, addresses []string) ([]UpstreamServer, error) {
+	selected := make([]UpstreamServer, 0, len(addresses))
+	for _, address := range addresses {
+		i := slices.IndexFunc(servers, func(s UpstreamServer) bool { return sameServer(addPortToServer(address), s.Server) })
+		if i == -1 {
+			return nil, fmt.Errorf("%v server: %w", address, ErrServerNotFound)
+		}
+		selected = append(selected, servers[i])
+	}
+	return selected, nil
+}
+
+// setCanaryWeights updates the weights of the servers to send the percentage of the traffic to the canary servers.
+// Every stable server gets a weight proportional to the stable traffic and to the number of canary servers,
+// and every canary server a weight proportional to the canary traffic and to the number of stable servers.
+// The canary servers are set up, in case they were down before the release.
+func (client *NginxClient) setCanaryWeights(ctx context.Context, upstream string, stable, canaries []UpstreamServer, percent int) error {
+	stableWeight := (100 - percent) * len(canaries)
+	canaryWeight := percent * len(stable)
+	divisor := gcd(stableWeight, canaryWeight)
+
+	var servers []UpstreamServer
+	for _, server := range stable {
+		weight := max(stableWeight/divisor, 1)
+		server.Weight = &weight
+		if percent == 100 {
+			down := true
+			server.Down = &down
+		}
+		servers = append(servers, server)
+	}
+	for _, server := range canaries {
+		weight := canaryWeight / divisor
+		server.Weight = &weight
+		server.Down = &defaultDown
+		servers = append(servers, server)
+	}
+
+	_, err := applyUpdates(client.updateConcurrency, servers, func(server UpstreamServer) error {
+		return client.UpdateHTTPServer(ctx, upstream, server)
+	})
+	return err
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// canaryPeers returns the peers of the canary servers. A server with resolve or service has no peer of its own,
+// so its peers are those of the servers resolved from it, which are read again as they change with DNS.
+// A canary server without peers fails the release, as its traffic can't be observed.
+func (client *NginxClient) canaryPeers(ctx context.Context, upstream string, canaries []UpstreamServer) ([]Peer, error) {
+	u, err := client.GetUpstreamWithFields(ctx, upstream, "peers")
+	if err != nil {
+		return nil, err
+	}
+	resolved := newHTTPServerPeers(ctx, client, upstream, u.Peers, peerID)
+	var peers []Peer
+	for _, canary := range canaries {
+		canaryPeers, err := resolved.peersOf(canary.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(canaryPeers) == 0 {
+			return nil, fmt.Errorf("no peers of %v server: %w", canary.Server, ErrServerNotFound)
+		}
+		peers = append(peers, canaryPeers...)
+	}
+	return peers, nil
+}
+
+// observe sets the responses, error rate and response time of the canary peers during the step.
+func (s *CanaryStep) observe(before, after []Peer) {
+	var weightedTime uint64
+	for _, peer := range after {
+		var responses, responses5xx uint64
+		i := slices.IndexFunc(before, func(p Peer) bool { return p.ID == peer.ID })
+		if i != -1 && peer.Responses.Total >= before[i].Responses.Total {
+			responses = peer.Responses.Total - before[i].Responses.Total
+			responses5xx = peer.Responses.Responses5xx - min(before[i].Responses.Responses5xx, peer.Responses.Responses5xx)
+		} else {
+			// The peer is new or its statistics were reset.
+			responses = peer.Responses.Total
+			responses5xx = peer.Responses.Responses5xx
+		}
+		s.Responses += responses
+		s.Responses5xx += responses5xx
+		weightedTime += peer.ResponseTime * responses
+	}
+	if s.Responses > 0 {
+		s.ErrorRate = float64(s.Responses5xx) / float64(s.Responses)
+		s.ResponseTime = time.Duration(weightedTime/s.Responses) * time.Millisecond
+	}
+}
+
+// breach returns the reason the step breaches a threshold of the release, or an empty string.
+func (canary CanaryRelease) breach(step CanaryStep) string {
+	if canary.MaxErrorRate > 0 && step.Responses > 0 && step.Responses >= canary.MinRequests && step.ErrorRate > canary.MaxErrorRate {
+		return fmt.Sprintf("error rate %.3f of the canary servers is above %v", step.ErrorRate, canary.MaxErrorRate)
+	}
+	if canary.MaxResponseTime > 0 && step.ResponseTime > canary.MaxResponseTime {
+		return fmt.Sprintf("response time %v of the canary servers is above %v", step.ResponseTime, canary.MaxResponseTime)
+	}
+	return ""
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunCanary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		failingPercent  int
+		canaryDown      bool
+		expectedWeights map[int]int
+		expectedDown    map[int]bool
+		expectedSteps   []int
+	}{
+		{
+			name:            "all steps succeed",
+			expectedWeights: map[int]int{0: 1, 1: 1, 2: 1},
+			expectedDown:    map[int]bool{0: true, 1: true, 2: false},
+			expectedSteps:   []int{25, 50, 100},
+		},
+		{
+			name:            "rolled back on errors",
+			failingPercent:  50,
+			expectedWeights: map[int]int{0: 3, 1: 4, 2: 5},
+			expectedDown:    map[int]bool{0: false, 1: false, 2: false},
+			expectedSteps:   []int{25, 50},
+		},
+		{
+			name:            "down canary is set up",
+			canaryDown:      true,
+			expectedWeights: map[int]int{0: 1, 1: 1, 2: 1},
+			expectedDown:    map[int]bool{0: true, 1: true, 2: false},
+			expectedSteps:   []int{25, 50, 100},
+		},
+		{
+			name:            "down canary is set down again on rollback",
+			failingPercent:  50,
+			canaryDown:      true,
+			expectedWeights: map[int]int{0: 3, 1: 4, 2: 5},
+			expectedDown:    map[int]bool{0: false, 1: false, 2: true},
+			expectedSteps:   []int{25, 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			weights := map[int]int{0: 3, 1: 4, 2: 5}
+			down := map[int]bool{}
+			var canaryWeights []int
+			var total, errors5xx uint64
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case r.Method == http.MethodPatch:
+					var server UpstreamServer
+					_ = json.NewDecoder(r.Body).Decode(&server)
+					id, _ := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/9/http/upstreams/test/servers/"), "/"))
+					weights[id] = *server.Weight
+					down[id] = server.Down != nil && *server.Down
+					if id == 2 {
+						canaryWeights = append(canaryWeights, *server.Weight)
+					}
+					w.WriteHeader(http.StatusOK)
+				case r.URL.Path == "/9/http/upstreams/test/servers":
+					_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:80","weight":3},{"id":1,"server":"10.0.0.2:80","weight":4},` +
+						`{"id":2,"server":"10.0.0.3:80","weight":5,"down":` + strconv.FormatBool(tt.canaryDown) + `}]`))
+				default:
+					// The canary responds with 1% of errors, or 50% at the failing step.
+					rate := uint64(1)
+					if tt.failingPercent != 0 && !down[0] && weights[2] == 2 && weights[0] == 1 {
+						rate = 50
+					}
+					total += 100
+					errors5xx += rate
+					peers := `{"peers":[{"id":0,"responses":{"total":1000}},{"id":2,"response_time":20,"responses":{"total":` +
+						strconv.FormatUint(total, 10) + `,"5xx":` + strconv.FormatUint(errors5xx, 10) + `}}]}`
+					_, _ = w.Write([]byte(peers))
+				}
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var steps []int
+			err = c.RunCanary(context.Background(), CanaryRelease{
+				Upstream:        "test",
+				Stable:          []string{"10.0.0.1", "10.0.0.2:80"},
+				Canary:          []string{"10.0.0.3:80"},
+				Steps:           []int{25, 50, 100},
+				Interval:        time.Millisecond,
+				MaxErrorRate:    0.1,
+				MaxResponseTime: time.Second,
+				MinRequests:     10,
+				Progress: func(step CanaryStep) {
+					if step.Responses != 100 || step.ResponseTime != 20*time.Millisecond {
+						t.Errorf("unexpected step: %+v", step)
+					}
+					steps = append(steps, step.Percent)
+				},
+			})
+
+			if tt.failingPercent == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.failingPercent != 0 {
+				var canaryErr *CanaryError
+				if !errors.As(err, &canaryErr) || !errors.Is(err, ErrCanaryRolledBack) {
+					t.Fatalf("expected %v, got %v", ErrCanaryRolledBack, err)
+				}
+				if canaryErr.Step.Percent != tt.failingPercent || canaryErr.Step.ErrorRate != 0.5 || canaryErr.RollbackErr != nil {
+					t.Fatalf("unexpected error: %+v", canaryErr)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(steps, tt.expectedSteps) {
+				t.Errorf("expected steps %v, got %v", tt.expectedSteps, steps)
+			}
+			if !reflect.DeepEqual(weights, tt.expectedWeights) || !reflect.DeepEqual(down, tt.expectedDown) {
+				t.Errorf("expected weights %v and down %v, got %v and %v", tt.expectedWeights, tt.expectedDown, weights, down)
+			}
+			if canaryWeights[0] != 2 || canaryWeights[1] != 2 {
+				t.Errorf("expected the canary weights of the first steps to be 2, got %v", canaryWeights)
+			}
+		})
+	}
+}
+
+func TestRunCanaryInvalidStep(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewNginxClient("http://127.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = c.RunCanary(context.Background(), CanaryRelease{Upstream: "test", Stable: []string{"a"}, Canary: []string{"b"}, Steps: []int{0}})
+	if !errors.Is(err, ErrInvalidCanaryStep) {
+		t.Fatalf("expected %v, got %v", ErrInvalidCanaryStep, err)
+	}
+}
+
+func TestRunCanaryResolvedServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		servers       string
+		expectedErr   error
+		expectedSteps []int
+	}{
+		{
+			name: "peers of the resolved servers",
+			servers: `[{"id":0,"server":"10.0.0.1:80"},{"id":2,"server":"backend.example.com","resolve":true},` +
+				`{"id":3,"server":"10.0.0.3:80","parent":2},{"id":4,"server":"10.0.0.4:80","parent":2}]`,
+			expectedSteps: []int{50},
+		},
+		{
+			name:        "no resolved servers",
+			servers:     `[{"id":0,"server":"10.0.0.1:80"},{"id":2,"server":"backend.example.com","resolve":true}]`,
+			expectedErr: ErrServerNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var total uint64
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case r.Method == http.MethodPatch:
+					w.WriteHeader(http.StatusOK)
+				case r.URL.Path == "/9/http/upstreams/test/servers":
+					_, _ = w.Write([]byte(tt.servers))
+				default:
+					// The resolved servers respond with 50% of errors.
+					total += 100
+					counts := `{"total":` + strconv.FormatUint(total, 10) + `,"5xx":` + strconv.FormatUint(total/2, 10) + `}`
+					_, _ = w.Write([]byte(`{"peers":[{"id":0,"responses":{"total":1000}},` +
+						`{"id":3,"responses":` + counts + `},{"id":4,"responses":` + counts + `}]}`))
+				}
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var steps []int
+			err = c.RunCanary(context.Background(), CanaryRelease{
+				Upstream:     "test",
+				Stable:       []string{"10.0.0.1:80"},
+				Canary:       []string{"backend.example.com"},
+				Steps:        []int{50, 100},
+				Interval:     time.Millisecond,
+				MaxErrorRate: 0.1,
+				Progress: func(step CanaryStep) {
+					if step.Responses != 200 || step.Responses5xx != 100 {
+						t.Errorf("unexpected step: %+v", step)
+					}
+					steps = append(steps, step.Percent)
+				},
+			})
+
+			var canaryErr *CanaryError
+			if !errors.As(err, &canaryErr) || canaryErr.RollbackErr != nil {
+				t.Fatalf("expected %v, got %v", ErrCanaryRolledBack, err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+			if !reflect.DeepEqual(steps, tt.expectedSteps) {
+				t.Errorf("expected steps %v, got %v", tt.expectedSteps, steps)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// writableProbePath is the path probed with a POST request to check whether the API is writable.
+const writableProbePath = "nginx"
+
+// capabilitiesCheckInterval is how often the cached capabilities are checked against the generation of the configuration.
+const capabilitiesCheckInterval = 10 * time.Second
+
+// apiRequirement is the minimum API version, and NGINX Plus release if not zero, which provides an API path.
+type apiRequirement struct {
+	path           string
+	minAPIVersion  int
+	minPlusRelease int
+}
+
+// apiRequirements are the API paths which are not available in every API version supported by the client.
+var apiRequirements = []apiRequirement{
+	{path: "resolvers", minAPIVersion: 5},
+	{path: "http/location_zones", minAPIVersion: 5},
+	{path: "http/limit_reqs", minAPIVersion: 6},
+	{path: "http/limit_conns", minAPIVersion: 6},
+	{path: "stream/limit_conns", minAPIVersion: 6},
+	{path: "workers", minAPIVersion: 9},
+	{path: "license", minAPIVersion: 9, minPlusRelease: 33},
+}
+
+// requirementOf returns the requirement of the API path, if any. The path may include a query.
+func requirementOf(path string) (apiRequirement, bool) {
+	path, _, _ = strings.Cut(path, "?")
+	for _, req := range apiRequirements {
+		if path == req.path || strings.HasPrefix(path, req.path+"/") {
+			return req, true
+		}
+	}
+	return apiRequirement{}, false
+}
+
+// checkAPIVersion returns an error wrapping ErrNotSupported if the API path is not available in the API version of the client.
+func checkAPIVersion(path string, apiVersion int) error {
+	req, ok := requirementOf(path)
+	if !ok || apiVersion >= req.minAPIVersion {
+		return nil
+	}
+	return fmt.Errorf("%v: %w in API version %v, API version %v or later is required", req.path, ErrNotSupported, apiVersion, req.minAPIVersion)
+}
+
+// Capabilities describes what the API of the NGINX Plus instance provides.
+type Capabilities struct {
+	// Endpoints are the endpoints of the root of the API, for example "nginx", "http" and "stream".
+	Endpoints []string
+	// HTTPEndpoints are the endpoints under "http", for example "upstreams" and "keyvals".
+	HTTPEndpoints []string
+	// StreamEndpoints are the endpoints under "stream". They are empty if the stream API is not available.
+	StreamEndpoints []string
+	// APIVersion is the version of the API used by the client.
+	APIVersion int
+	// PlusRelease is the NGINX Plus release, for example 33 for R33, or zero if it is unknown.
+	PlusRelease int
+	// Generation is the generation of the configuration the capabilities were discovered for.
+	Generation uint64
+}
+
+// HasEndpoint reports whether the API path, such as "stream" or "http/keyvals", is available.
+func (c *Capabilities) HasEndpoint(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+	first, rest, _ := strings.Cut(path, "/")
+	if !slices.Contains(c.Endpoints, first) {
+		return false
+	}
+	second, _, _ := strings.Cut(rest, "/")
+	switch {
+	case second == "":
+		return true
+	case first == "http":
+		return slices.Contains(c.HTTPEndpoints, second)
+	case first == "stream":
+		return slices.Contains(c.StreamEndpoints, second)
+	default:
+		return true
+	}
+}
+
+// Supports returns an error wrapping ErrNotSupported if the API path is not available,
+// explaining the minimum API version or NGINX Plus release required.
+func (c *Capabilities) Supports(path string) error {
+	if err := checkAPIVersion(path, c.APIVersion); err != nil {
+		return err
+	}
+	if req, ok := requirementOf(path); ok && c.PlusRelease < req.minPlusRelease {
+		if c.PlusRelease == 0 {
+			return fmt.Errorf("%v: %w by an unknown NGINX Plus release, release R%v or later is required", req.path, ErrNotSupported, req.minPlusRelease)
+		}
+		return fmt.Errorf("%v: %w in NGINX Plus release R%v, release R%v or later is required", req.path, ErrNotSupported, c.PlusRelease, req.minPlusRelease)
+	}
+	if !c.HasEndpoint(path) {
+		return fmt.Errorf("%v: %w by the server", path, ErrNotSupported)
+	}
+	return nil
+}
+
+func (c *Capabilities) clone() *Capabilities {
+	clone := *c
+	clone.Endpoints = slices.Clone(c.Endpoints)
+	clone.HTTPEndpoints = slices.Clone(c.HTTPEndpoints)
+	clone.StreamEndpoints = slices.Clone(c.StreamEndpoints)
+	return &clone
+}
+
+// capabilitiesCache holds the capabilities discovered by the client.
+type capabilitiesCache struct {
+	checked      time.Time
+	capabilities *Capabilities
+	// writable is whether the probe found the API writable, or nil if it was not probed.
+	// It is forgotten when the configuration is reloaded.
+	writable *bool
+	// readOnly is whether a request was rejected with the MethodDisabled error.
+	// It is forgotten when the configuration is reloaded.
+	readOnly bool
+	mu       sync.Mutex
+}
+
+// Capabilities returns the capabilities of the API. They are discovered once and cached.
+// The cached capabilities are discovered again when the configuration is reloaded,
+// which is checked at most every 10 seconds.
+func (client *NginxClient) Capabilities(ctx context.Context) (*Capabilities, error) {
+	ctx = withOperation(ctx, Operation{Name: "Capabilities"})
+	cache := &client.capabilities
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.capabilities != nil && time.Since(cache.checked) < capabilitiesCheckInterval {
+		return cache.capabilities.clone(), nil
+	}
+
+	if cache.capabilities != nil {
+		var info NginxInfo
+		err := client.getWithFields(ctx, "nginx", []string{"generation"}, &info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check capabilities: %w", err)
+		}
+		if info.Generation == cache.capabilities.Generation {
+			cache.checked = time.Now()
+			return cache.capabilities.clone(), nil
+		}
+	}
+
+	reloaded := cache.capabilities != nil
+	capabilities, err := client.discoverCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.capabilities = capabilities
+	cache.checked = time.Now()
+	if reloaded {
+		cache.writable = nil
+		cache.readOnly = false
+	}
+	return capabilities.clone(), nil
+}
+
+// cachedCapabilities returns the cached capabilities if they were checked within the check interval, or nil.
+func (client *NginxClient) cachedCapabilities() *Capabilities {
+	cache := &client.capabilities
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.capabilities == nil || time.Since(cache.checked) >= capabilitiesCheckInterval {
+		return nil
+	}
+	return cache.capabilities.clone()
+}
+
+// RefreshCapabilities discovers the capabilities of the API again, replacing the cached ones.
+func (client *NginxClient) RefreshCapabilities(ctx context.Context) (*Capabilities, error) {
+	cache := &client.capabilities
+	cache.mu.Lock()
+	cache.capabilities = nil
+	cache.writable = nil
+	cache.readOnly = false
+	cache.mu.Unlock()
+	return client.Capabilities(ctx)
+}
+
+// discoverCapabilities walks the root, http and stream endpoints of the API.
+func (client *NginxClient) discoverCapabilities(ctx context.Context) (*Capabilities, error) {
+	capabilities := &Capabilities{APIVersion: client.apiVersion}
+	err := client.get(ctx, "", &capabilities.Endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover capabilities: %w", err)
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		var info NginxInfo
+		if err := client.get(groupCtx, "nginx", &info); err != nil {
+			return err
+		}
+		capabilities.Generation = info.Generation
+		// Not every build, for example of NGINX Plus for testing, includes the release.
+		capabilities.PlusRelease, _ = extractPlusVersionValues(info.Build)
+		return nil
+	})
+	if slices.Contains(capabilities.Endpoints, "http") {
+		group.Go(func() error {
+			return client.get(groupCtx, "http", &capabilities.HTTPEndpoints)
+		})
+	}
+	if slices.Contains(capabilities.Endpoints, "stream") {
+		group.Go(func() error {
+			return client.get(groupCtx, "stream", &capabilities.StreamEndpoints)
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to discover capabilities: %w", err)
+	}
+
+	return capabilities, nil
+}
+
+// probeWritable reports whether the API is writable. The probe is a POST request to the nginx endpoint,
+// which modifies nothing: a writable API rejects the method for the endpoint with the MethodNotSupported error,
+// while a read-only API rejects every method other than GET with the MethodDisabled error.
+// Any other response, for example from a proxy denying the request, proves nothing and is returned as an error.
+func (client *NginxClient) probeWritable(ctx context.Context) (bool, error) {
+	path := writableProbePath
+	apiURL := fmt.Sprintf("%v/%v/%v", client.apiEndpoint, client.apiVersion, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader("{}"))
+	if err != nil {
+		return false, fmt.Errorf("failed to create a post request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.do(req, path, struct{}{})
+	if err != nil {
+		return false, fmt.Errorf("failed to post %v: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	apiErr := newAPIError(fmt.Sprintf("expected %v response, got %v", http.StatusMethodNotAllowed, resp.StatusCode),
+		req, path, http.StatusMethodNotAllowed, resp)
+	switch {
+	case resp.StatusCode != http.StatusMethodNotAllowed:
+		return false, apiErr
+	case errors.Is(apiErr, ErrMethodDisabled):
+		return false, nil
+	case apiErr.Code == "MethodNotSupported":
+		return true, nil
+	default:
+		return false, apiErr
+	}
+}
+
+// IsWritable reports whether the API is writable, that is the write parameter of the api directive is on.
+// The API is probed the first time, and again after the configuration is reloaded, with a POST request to the nginx endpoint,
+// which modifies nothing. The probe is sent through the middlewares as the IsWritable operation.
+// If the response of the probe is not the one of NGINX Plus, for example because a proxy denied it, an error is returned.
+// It is false once a request has been rejected with the MethodDisabled error, until the configuration is reloaded.
+func (client *NginxClient) IsWritable(ctx context.Context) (bool, error) {
+	ctx = withOperation(ctx, Operation{Name: "IsWritable"})
+	// The capabilities detect reloads, which forget whether the API is writable.
+	if _, err := client.Capabilities(ctx); err != nil {
+		return false, fmt.Errorf("failed to check if the API is writable: %w", err)
+	}
+
+	cache := &client.capabilities
+	cache.mu.Lock()
+	readOnly, writable := cache.readOnly, cache.writable
+	cache.mu.Unlock()
+	if readOnly {
+		return false, nil
+	}
+	if writable != nil {
+		return *writable, nil
+	}
+
+	probed, err := client.probeWritable(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if the API is writable: %w", err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.writable == nil {
+		cache.writable = &probed
+	}
+	return *cache.writable && !cache.readOnly, nil
+}
+
+// checkWritable returns an error wrapping ErrReadOnly if a previous request which modifies the API
+// was rejected with the MethodDisabled error. It sends no request unless the API has been found to be read-only,
+// in which case the capabilities are checked, so that writes are allowed again once the configuration is reloaded.
+func (client *NginxClient) checkWritable(ctx context.Context) error {
+	cache := &client.capabilities
+	cache.mu.Lock()
+	readOnly := cache.readOnly
+	cache.mu.Unlock()
+	if !readOnly {
+		return nil
+	}
+
+	if _, err := client.Capabilities(ctx); err != nil {
+		return fmt.Errorf("failed to check if the API is writable: %w", err)
+	}
+	cache.mu.Lock()
+	readOnly = cache.readOnly
+	cache.mu.Unlock()
+	if readOnly {
+		return fmt.Errorf("%v: %w", client.endpoint(), ErrReadOnly)
+	}
+	return nil
+}
+
+// markReadOnly records that the API rejected a request with the MethodDisabled error.
+func (client *NginxClient) markReadOnly() {
+	cache := &client.capabilities
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.readOnly = true
+}
+
+// writeError returns the error for the API error of a request which modifies the API,
+// recording that the API is read-only if the request was rejected with the MethodDisabled error.
+func (client *NginxClient) writeError(apiErr *APIError) error {
+	if !errors.Is(apiErr, ErrMethodDisabled) {
+		return apiErr
+	}
+	client.markReadOnly()
+	return fmt.Errorf("%w: %w", ErrReadOnly, apiErr)
+}
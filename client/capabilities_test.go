@@ -0,0 +1,370 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	var generation atomic.Uint64
+	generation.Store(1)
+	var mu sync.Mutex
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte(`{"error":{"status":405,"text":"method disabled","code":"MethodDisabled"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/9/":
+			_, _ = w.Write([]byte(`["nginx","processes","connections","slabs","http","stream","resolvers","ssl","workers","license"]`))
+		case "/9/nginx":
+			_, _ = w.Write([]byte(`{"build":"nginx-plus-r32","generation":` + strconv.FormatUint(generation.Load(), 10) + `}`))
+		case "/9/http":
+			_, _ = w.Write([]byte(`["requests","server_zones","caches","upstreams","keyvals"]`))
+		case "/9/stream":
+			_, _ = w.Write([]byte(`["server_zones","upstreams"]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	count := func(request string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[request]
+	}
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &Capabilities{
+		Endpoints:       []string{"nginx", "processes", "connections", "slabs", "http", "stream", "resolvers", "ssl", "workers", "license"},
+		HTTPEndpoints:   []string{"requests", "server_zones", "caches", "upstreams", "keyvals"},
+		StreamEndpoints: []string{"server_zones", "upstreams"},
+		APIVersion:      9,
+		PlusRelease:     32,
+		Generation:      1,
+	}
+	if !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("expected capabilities %+v, got %+v", expected, capabilities)
+	}
+	if count("POST /9/nginx") != 0 {
+		t.Fatal("expected the capabilities to be discovered without probing if the API is writable")
+	}
+
+	if !capabilities.HasEndpoint("http/keyvals/zone") || capabilities.HasEndpoint("stream/limit_conns") {
+		t.Fatalf("unexpected endpoints: %+v", capabilities)
+	}
+	if err := capabilities.Supports("http/upstreams?fields=peers"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := capabilities.Supports("stream/zone_sync"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+
+	license, err := c.GetNginxLicense(ctx)
+	if err != nil || !reflect.DeepEqual(license, &NginxLicense{}) {
+		t.Fatalf("expected an empty license, got %+v, %v", license, err)
+	}
+	if count("GET /9/license") != 0 {
+		t.Fatal("expected no request for the license of an unsupported release")
+	}
+	if count("GET /9/") != 1 {
+		t.Fatalf("expected the capabilities to be cached, got %v requests", count("GET /9/"))
+	}
+
+	// Once the check interval has passed, the capabilities are discovered again after a reload.
+	c.capabilities.mu.Lock()
+	c.capabilities.checked = time.Time{}
+	c.capabilities.mu.Unlock()
+	if _, err := c.Capabilities(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count("GET /9/") != 1 {
+		t.Fatal("expected the capabilities to be kept for the same generation")
+	}
+
+	generation.Store(2)
+	c.capabilities.mu.Lock()
+	c.capabilities.checked = time.Time{}
+	c.capabilities.mu.Unlock()
+	capabilities, err = c.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if capabilities.Generation != 2 || count("GET /9/") != 2 {
+		t.Fatalf("expected the capabilities to be discovered again after a reload, got %+v", capabilities)
+	}
+
+	if _, err := c.RefreshCapabilities(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count("GET /9/") != 3 {
+		t.Fatal("expected the capabilities to be discovered again when refreshed")
+	}
+
+	writable, err := c.IsWritable(ctx)
+	if err != nil || writable {
+		t.Fatalf("expected the API to be read-only, got %v, %v", writable, err)
+	}
+	if _, err := c.IsWritable(ctx); err != nil || count("POST /9/nginx") != 1 {
+		t.Fatalf("expected the API to be probed once, got %v probes, %v", count("POST /9/nginx"), err)
+	}
+}
+
+func TestAPIVersionNotSupported(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithAPIVersion(4))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// The stats getters return empty stats for the endpoints the API version doesn't provide.
+	getters := []struct {
+		call func() (any, error)
+		want any
+		name string
+	}{
+		{name: "resolvers", call: func() (any, error) { return c.GetResolvers(ctx) }, want: new(Resolvers)},
+		{name: "location zones", call: func() (any, error) { return c.GetLocationZones(ctx) }, want: new(LocationZones)},
+		{name: "http limit requests", call: func() (any, error) { return c.GetHTTPLimitReqs(ctx) }, want: new(HTTPLimitRequests)},
+		{name: "http connections limit", call: func() (any, error) { return c.GetHTTPConnectionsLimit(ctx) }, want: new(HTTPLimitConnections)},
+		{name: "stream connections limit", call: func() (any, error) { return c.GetStreamConnectionsLimit(ctx) }, want: new(StreamLimitConnections)},
+		{name: "workers", call: func() (any, error) { return c.GetWorkersWithFields(ctx, "pid") }, want: []*Workers(nil)},
+	}
+	for _, tt := range getters {
+		got, err := tt.call()
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%v: expected %v, got %v, %v", tt.name, tt.want, got, err)
+		}
+	}
+
+	if err := c.ResetResolver(ctx, "zone"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestReadOnlyAPI(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte(`{"error":{"status":405,"text":"method disabled","code":"MethodDisabled"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/9/":
+			_, _ = w.Write([]byte(`["nginx","http"]`))
+		case "/9/http":
+			_, _ = w.Write([]byte(`["upstreams","keyvals"]`))
+		case "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests)
+	}
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	// The first write is sent, and its rejection marks the API read-only.
+	err = c.AddKeyValPair(ctx, "zone", "key", "val")
+	if !errors.Is(err, ErrReadOnly) || !errors.Is(err, ErrMethodDisabled) {
+		t.Fatalf("expected %v, got %v", ErrReadOnly, err)
+	}
+	mu.Lock()
+	sent := slices.Clone(requests)
+	mu.Unlock()
+	if !reflect.DeepEqual(sent, []string{"POST /9/http/keyvals/zone"}) {
+		t.Fatalf("expected only the write, got %v", sent)
+	}
+
+	// The client discovers the capabilities once, to detect reloads, then fails fast.
+	_, _, _, err = c.UpdateHTTPServers(ctx, "test", []UpstreamServer{{Server: "127.0.0.1:80"}})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected %v, got %v", ErrReadOnly, err)
+	}
+	requestsBefore := count()
+	err = c.AddHTTPServer(ctx, "test", UpstreamServer{Server: "127.0.0.1:80"})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected %v, got %v", ErrReadOnly, err)
+	}
+	if count() != requestsBefore {
+		t.Fatalf("expected no request to a read-only API, got %v", requests[requestsBefore:])
+	}
+
+	writable, err := c.IsWritable(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if writable {
+		t.Fatal("expected the API to be read-only")
+	}
+}
+
+func TestIsWritable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		writable bool
+		err      bool
+	}{
+		{
+			name:     "writable",
+			status:   http.StatusMethodNotAllowed,
+			body:     `{"error":{"status":405,"text":"method not supported","code":"MethodNotSupported"}}`,
+			writable: true,
+		},
+		{
+			name:   "read-only",
+			status: http.StatusMethodNotAllowed,
+			body:   `{"error":{"status":405,"text":"method disabled","code":"MethodDisabled"}}`,
+		},
+		{
+			name:   "denied by a proxy",
+			status: http.StatusForbidden,
+			body:   `forbidden`,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+					return
+				}
+				switch r.URL.Path {
+				case "/9/":
+					_, _ = w.Write([]byte(`["nginx"]`))
+				default:
+					_, _ = w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			writable, err := c.IsWritable(context.Background())
+			if (err != nil) != tt.err || writable != tt.writable {
+				t.Fatalf("expected writable %v and error %v, got %v, %v", tt.writable, tt.err, writable, err)
+			}
+
+			// Only the rejection of a write marks the API read-only, so writes are still sent.
+			err = c.checkWritable(context.Background())
+			if err != nil {
+				t.Fatalf("expected writes to be allowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetStatsCapabilities(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/9/":
+			_, _ = w.Write([]byte(`["nginx","processes","connections","slabs","http","resolvers","ssl","workers"]`))
+		case "/9/http":
+			_, _ = w.Write([]byte(`["requests","server_zones","caches","upstreams"]`))
+		case "/9/workers":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+	count := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[path]
+	}
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.GetStats(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count("/9/") != 1 || count("/9/http") != 0 {
+		t.Fatalf("expected the stats to list only the root endpoints, got %v", requests)
+	}
+
+	// The stats reuse the cached capabilities.
+	if _, err := c.Capabilities(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStats(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count("/9/") != 2 || count("/9/http") != 1 {
+		t.Fatalf("expected the stats to reuse the cached capabilities, got %v", requests)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrConflict is returned when the servers of an upstream are changed concurrently by another writer
+// and they still differ from the desired servers after the conflict retries.
+var ErrConflict = errors.New("upstream changed concurrently")
+
+// WithConflictRetries enables optimistic concurrency for the changes to the servers of upstreams.
+//
+// After UpdateHTTPServers or UpdateStreamServers make their changes, the servers of the upstream are read again.
+// If they differ from the desired servers, because another writer changed the upstream in between, or if a change failed
+// because the server was removed or added concurrently, the changes are planned and made again, up to the retries.
+// Servers with the same address, added by concurrent writers, are removed too, keeping the server with the lowest ID.
+// If the servers still differ after the retries, the error wraps ErrConflict.
+//
+// AddHTTPServer and AddStreamServer check that the server was not added concurrently by another writer.
+// If it was, the server with the lowest ID is kept and the others are removed, so that concurrent writers converge.
+// The writer whose server is removed gets an error wrapping ErrServerExists.
+func WithConflictRetries(retries int) Option {
+	return func(o *NginxClient) {
+		o.conflictRetries = retries
+	}
+}
+
+// isConflict reports whether the error of the changes to servers can be caused by concurrent changes,
+// that is whether every joined error is ErrServerNotFound or ErrServerExists.
+func isConflict(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		for _, e := range errs {
+			if !isConflict(e) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	}
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		return isConflict(wrapped)
+	}
+	return errors.Is(err, ErrServerNotFound) || errors.Is(err, ErrServerExists)
+}
+
+// determineHTTPServerUpdates determines the changes to the servers of NGINX like determineUpdates.
+// If conflict retries are enabled, the servers with the same address added by concurrent writers are also removed,
+// keeping the server with the lowest ID.
+func (client *NginxClient) determineHTTPServerUpdates(servers, serversInNginx []UpstreamServer) (toAdd, toDelete, toUpdate []UpstreamServer) {
+	if client.conflictRetries <= 0 {
+		return determineUpdates(servers, serversInNginx)
+	}
+	unique, duplicates := splitDuplicates(configuredServers(serversInNginx), httpServerAddress, httpServerID)
+	toAdd, toDelete, toUpdate = determineUpdates(servers, unique)
+	return toAdd, append(toDelete, duplicates...), toUpdate
+}
+
+// determineStreamServerUpdates determines the changes to the servers of NGINX like determineStreamUpdates.
+// If conflict retries are enabled, the servers with the same address added by concurrent writers are also removed,
+// keeping the server with the lowest ID.
+func (client *NginxClient) determineStreamServerUpdates(servers, serversInNginx []StreamUpstreamServer) (toAdd, toDelete, toUpdate []StreamUpstreamServer) {
+	if client.conflictRetries <= 0 {
+		return determineStreamUpdates(servers, serversInNginx)
+	}
+	unique, duplicates := splitDuplicates(configuredStreamServers(serversInNginx), streamServerAddress, streamServerID)
+	toAdd, toDelete, toUpdate = determineStreamUpdates(servers, unique)
+	return toAdd, append(toDelete, duplicates...), toUpdate
+}
+
+// retryConflicts makes the changes with update. If conflict retries are enabled, it checks with converged that
+// the servers of the upstream are the desired servers, and makes the changes again after conflicts.
+func (client *NginxClient) retryConflicts(ctx context.Context, upstream string, update func() error, converged func() (bool, error)) error {
+	err := update()
+	if client.conflictRetries <= 0 {
+		return err
+	}
+
+	for retry := 1; ; retry++ {
+		if err != nil && !isConflict(err) {
+			return err
+		}
+		ok, checkErr := converged()
+		if checkErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to check for conflicts: %w", checkErr))
+		}
+		if ok {
+			// The errors were caused by concurrent changes, which made the servers converge anyway.
+			return nil
+		}
+		if retry > client.conflictRetries {
+			return errors.Join(fmt.Errorf("%w: servers differ after %v retries", ErrConflict, client.conflictRetries), err)
+		}
+		client.logConflict(ctx, upstream, retry, err)
+		err = update()
+	}
+}
+
+// httpServersConverged reports whether the servers of the upstream are the servers.
+func (client *NginxClient) httpServersConverged(ctx context.Context, upstream string, servers []UpstreamServer) (bool, error) {
+	serversInNginx, err := client.GetHTTPServers(ctx, upstream)
+	if err != nil {
+		return false, err
+	}
+	toAdd, toDelete, toUpdate := client.determineHTTPServerUpdates(servers, serversInNginx)
+	return len(toAdd) == 0 && len(toDelete) == 0 && len(toUpdate) == 0, nil
+}
+
+// streamServersConverged reports whether the servers of the stream upstream are the servers.
+func (client *NginxClient) streamServersConverged(ctx context.Context, upstream string, servers []StreamUpstreamServer) (bool, error) {
+	serversInNginx, err := client.GetStreamServers(ctx, upstream)
+	if err != nil {
+		return false, err
+	}
+	toAdd, toDelete, toUpdate := client.determineStreamServerUpdates(servers, serversInNginx)
+	return len(toAdd) == 0 && len(toDelete) == 0 && len(toUpdate) == 0, nil
+}
+
+// resolveConcurrentHTTPAdd checks that the server added with the ID was not also added by another writer.
+// If it was, the server is removed unless it has the lowest ID.
+func (client *NginxClient) resolveConcurrentHTTPAdd(ctx context.Context, upstream, server string, id int) error {
+	servers, err := client.GetHTTPServers(ctx, upstream)
+	if err != nil {
+		return fmt.Errorf("failed to check %v server of %v upstream for conflicts: %w", server, upstream, err)
+	}
+	if lowestID(configuredServers(servers), server, httpServerAddress, httpServerID) == id {
+		return nil
+	}
+
+	path := fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, id)
+	err = client.delete(expectingErrors(ctx, ErrServerNotFound), path, http.StatusOK)
+	if err != nil && !errors.Is(err, ErrServerNotFound) {
+		return fmt.Errorf("failed to remove %v server added concurrently to %v upstream: %w", server, upstream, err)
+	}
+	return fmt.Errorf("failed to add %v server to %v upstream: %w", server, upstream, ErrServerExists)
+}
+
+// resolveConcurrentStreamAdd checks that the server added with the ID was not also added by another writer.
+// If it was, the server is removed unless it has the lowest ID.
+func (client *NginxClient) resolveConcurrentStreamAdd(ctx context.Context, upstream, server string, id int) error {
+	servers, err := client.GetStreamServers(ctx, upstream)
+	if err != nil {
+		return fmt.Errorf("failed to check %v stream server of %v upstream for conflicts: %w", server, upstream, err)
+	}
+	if lowestID(configuredStreamServers(servers), server, streamServerAddress, streamServerID) == id {
+		return nil
+	}
+
+	path := fmt.Sprintf("stream/upstreams/%v/servers/%v", upstream, id)
+	err = client.delete(expectingErrors(ctx, ErrServerNotFound), path, http.StatusOK)
+	if err != nil && !errors.Is(err, ErrServerNotFound) {
+		return fmt.Errorf("failed to remove %v stream server added concurrently to %v upstream: %w", server, upstream, err)
+	}
+	return fmt.Errorf("failed to add %v stream server to %v upstream: %w", server, upstream, ErrServerExists)
+}
+
+// lowestID returns the lowest ID of the servers with the address, or -1 if there are none.
+func lowestID[S any](servers []S, server string, address func(S) string, id func(S) int) int {
+	lowest := -1
+	for _, s := range servers {
+		if sameServer(server, address(s)) && (lowest == -1 || id(s) < lowest) {
+			lowest = id(s)
+		}
+	}
+	return lowest
+}
+
+// splitDuplicates splits the servers of NGINX with the same address, which can be added by concurrent writers,
+// keeping the server with the lowest ID.
+func splitDuplicates[S any](servers []S, address func(S) string, id func(S) int) (unique []S, duplicates []S) {
+	for _, s := range servers {
+		if lowestID(servers, address(s), address, id) == id(s) {
+			unique = append(unique, s)
+		} else {
+			duplicates = append(duplicates, s)
+		}
+	}
+	return unique, duplicates
+}
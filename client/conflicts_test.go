@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeUpstream is an HTTP upstream of a fake NGINX, changed concurrently by another writer with the hook,
+// called before every request.
+type fakeUpstream struct {
+	hook    func(u *fakeUpstream, r *http.Request)
+	servers []UpstreamServer
+	nextID  int
+	mu      sync.Mutex
+}
+
+func (u *fakeUpstream) add(server string) int {
+	id := u.nextID
+	u.nextID++
+	u.servers = append(u.servers, UpstreamServer{ID: id, Server: server})
+	return id
+}
+
+func (u *fakeUpstream) addresses() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var addresses []string
+	for _, s := range u.servers {
+		addresses = append(addresses, s.Server)
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.hook != nil {
+		u.hook(u, r)
+	}
+
+	id, _ := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/9/http/upstreams/test/servers"), "/"))
+	i := slices.IndexFunc(u.servers, func(s UpstreamServer) bool { return s.ID == id })
+	switch r.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(u.servers)
+	case http.MethodPost:
+		var server UpstreamServer
+		_ = json.NewDecoder(r.Body).Decode(&server)
+		server.ID = u.add(server.Server)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(server)
+	case http.MethodDelete, http.MethodPatch:
+		if i == -1 {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"server not found","code":"UpstreamServerNotFound"}}`))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			u.servers = slices.Delete(u.servers, i, i+1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestUpdateHTTPServersWithConflictRetries(t *testing.T) {
+	t.Parallel()
+
+	u := &fakeUpstream{}
+	u.add("10.0.0.1:80")
+	u.add("10.0.0.3:80")
+	concurrent := true
+	u.hook = func(u *fakeUpstream, r *http.Request) {
+		// Another writer removes 10.0.0.3:80 and adds 10.0.0.4:80 while the servers are added.
+		if r.Method == http.MethodPost && concurrent {
+			concurrent = false
+			u.servers = slices.DeleteFunc(u.servers, func(s UpstreamServer) bool { return s.Server == "10.0.0.3:80" })
+			u.add("10.0.0.4:80")
+		}
+	}
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithConflictRetries(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	servers := []UpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.2:80"}}
+	added, deleted, _, err := c.UpdateHTTPServers(context.Background(), "test", servers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(added) != 1 || len(deleted) != 1 || deleted[0].Server != "10.0.0.4:80" {
+		t.Fatalf("unexpected changes: added %v, deleted %v", added, deleted)
+	}
+	if addresses := u.addresses(); !slices.Equal(addresses, []string{"10.0.0.1:80", "10.0.0.2:80"}) {
+		t.Fatalf("expected the servers to converge, got %v", addresses)
+	}
+
+	// Another writer keeps adding servers, so the servers never converge.
+	u.hook = func(u *fakeUpstream, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			u.add("10.0.1." + strconv.Itoa(u.nextID) + ":80")
+		}
+	}
+	u.mu.Lock()
+	u.add("10.0.0.5:80")
+	u.mu.Unlock()
+
+	_, _, _, err = c.UpdateHTTPServers(context.Background(), "test", servers)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected %v, got %v", ErrConflict, err)
+	}
+}
+
+func TestAddHTTPServerWithConflictRetries(t *testing.T) {
+	t.Parallel()
+
+	u := &fakeUpstream{}
+	concurrent := true
+	u.hook = func(u *fakeUpstream, r *http.Request) {
+		// Another writer adds the same server after the client checked that it doesn't exist.
+		if r.Method == http.MethodPost && concurrent {
+			concurrent = false
+			u.add("10.0.0.1:80")
+		}
+	}
+	ts := httptest.NewServer(u)
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithConflictRetries(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = c.AddHTTPServer(context.Background(), "test", UpstreamServer{Server: "10.0.0.1:80"})
+	if !errors.Is(err, ErrServerExists) {
+		t.Fatalf("expected %v, got %v", ErrServerExists, err)
+	}
+	if addresses := u.addresses(); !slices.Equal(addresses, []string{"10.0.0.1:80"}) {
+		t.Fatalf("expected the concurrent add to be resolved, got %v", addresses)
+	}
+
+	err = c.AddHTTPServer(context.Background(), "test", UpstreamServer{Server: "10.0.0.2:80"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDetermineHTTPServerUpdatesDuplicates(t *testing.T) {
+	t.Parallel()
+
+	servers := []UpstreamServer{{Server: "10.0.0.1:80"}}
+	nginxServers := []UpstreamServer{{ID: 2, Server: "10.0.0.1:80"}, {ID: 1, Server: "10.0.0.1:80"}}
+
+	tests := []struct {
+		name             string
+		expectedToDelete []UpstreamServer
+		conflictRetries  int
+	}{
+		{
+			name: "without conflict retries",
+		},
+		{
+			name:             "with conflict retries",
+			conflictRetries:  1,
+			expectedToDelete: []UpstreamServer{{ID: 2, Server: "10.0.0.1:80"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := NewNginxClient("http://127.0.0.1", WithConflictRetries(test.conflictRetries))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			toAdd, toDelete, toUpdate := c.determineHTTPServerUpdates(servers, nginxServers)
+			if len(toAdd) != 0 || len(toUpdate) != 0 || !slices.Equal(toDelete, test.expectedToDelete) {
+				t.Fatalf("unexpected changes: add %v, delete %v, update %v", toAdd, toDelete, toUpdate)
+			}
+		})
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	t.Parallel()
+
+	notFound := &APIError{Code: "UpstreamServerNotFound"}
+	serverError := &APIError{Code: "InternalError"}
+	tests := []struct {
+		err      error
+		name     string
+		expected bool
+	}{
+		{name: "conflict", err: notFound, expected: true},
+		{name: "wrapped conflict", err: fmt.Errorf("failed: %w", ErrServerExists), expected: true},
+		{name: "joined conflicts", err: errors.Join(notFound, ErrServerExists), expected: true},
+		{name: "conflict joined with another error", err: errors.Join(notFound, serverError), expected: false},
+		{name: "another error", err: serverError, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if got := isConflict(test.err); got != test.expected {
+				t.Fatalf("isConflict(%v) = %v, expected %v", test.err, got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyDesiredState is returned when a desired state document has none of the upstreams and keyval zones sections,
+// for example because the unmarshal function ignored the json tags of the fields.
+var ErrEmptyDesiredState = errors.New("desired state has no sections")
+
+// ErrEmptyUpstream is returned by ApplyDesiredState when an upstream of the desired state has no servers,
+// which would remove all its servers, unless the document allows empty upstreams.
+var ErrEmptyUpstream = errors.New("upstream has no servers")
+
+// DesiredState is a document of the desired dynamic state of an NGINX Plus instance: the servers of HTTP and stream upstreams
+// and the contents of HTTP and stream keyval zones. ApplyDesiredState reconciles the instance with it.
+// Upstreams and zones which are not in the document are left unchanged.
+// An upstream without servers is rejected, as it would remove all the servers of the upstream,
+// unless AllowEmptyUpstreams is true.
+//
+// The document is read from JSON by ParseDesiredState, or with another unmarshal function by ParseDesiredStateWith.
+//
+//	{
+//	  "http_upstreams": {"backend": [{"server": "10.0.0.1:80"}, {"server": "10.0.0.2:80", "weight": 2}]},
+//	  "stream_upstreams": {"dns": [{"server": "10.0.0.1:53"}]},
+//	  "http_keyvals": {"rate_limits": {"tenant1": "10r/s"}}
+//	}
+type DesiredState struct {
+	HTTPUpstreams   map[string][]UpstreamServer       `json:"http_upstreams,omitempty"`
+	StreamUpstreams map[string][]StreamUpstreamServer `json:"stream_upstreams,omitempty"`
+	HTTPKeyvals     map[string]KeyValPairs            `json:"http_keyvals,omitempty"`
+	StreamKeyvals   map[string]KeyValPairs            `json:"stream_keyvals,omitempty"`
+	// AllowEmptyUpstreams allows upstreams without servers, whose servers are all removed.
+	AllowEmptyUpstreams bool `json:"allow_empty_upstreams,omitempty"`
+}
+
+// ParseDesiredState reads a desired state document from JSON. Unknown fields are rejected, to catch typos,
+// and so is a document without any section, with ErrEmptyDesiredState.
+func ParseDesiredState(data []byte) (*DesiredState, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var state DesiredState
+	if err := decoder.Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to parse desired state: %w", err)
+	}
+	if state.empty() {
+		return nil, fmt.Errorf("failed to parse desired state: %w", ErrEmptyDesiredState)
+	}
+	return &state, nil
+}
+
+// ParseDesiredStateWith reads a desired state document with the unmarshal function, which must use the json tags
+// of the fields. An unmarshal function which ignores the json tags leaves the document empty,
+// so a document without any section is rejected with ErrEmptyDesiredState.
+func ParseDesiredStateWith(data []byte, unmarshal func(data []byte, v any) error) (*DesiredState, error) {
+	var state DesiredState
+	if err := unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse desired state: %w", err)
+	}
+	if state.empty() {
+		return nil, fmt.Errorf("failed to parse desired state: %w", ErrEmptyDesiredState)
+	}
+	return &state, nil
+}
+
+// checkEmptyUpstreams returns an error wrapping ErrEmptyUpstream for the first upstream without servers,
+// unless the document allows them.
+func (state *DesiredState) checkEmptyUpstreams() error {
+	if state.AllowEmptyUpstreams {
+		return nil
+	}
+	for _, upstream := range sortedKeys(state.HTTPUpstreams) {
+		if len(state.HTTPUpstreams[upstream]) == 0 {
+			return fmt.Errorf("%v upstream: %w", upstream, ErrEmptyUpstream)
+		}
+	}
+	for _, upstream := range sortedKeys(state.StreamUpstreams) {
+		if len(state.StreamUpstreams[upstream]) == 0 {
+			return fmt.Errorf("%v stream upstream: %w", upstream, ErrEmptyUpstream)
+		}
+	}
+	return nil
+}
+
+// empty reports whether the document has none of the sections.
+func (state *DesiredState) empty() bool {
+	return state.HTTPUpstreams == nil && state.StreamUpstreams == nil && state.HTTPKeyvals == nil && state.StreamKeyvals == nil
+}
+
+// KeyvalChanges are the changes made to a keyval zone by ApplyDesiredState.
+type KeyvalChanges struct {
+	// Err is the error of the changes, or nil if all the changes were made.
+	Err  error
+	Zone string
+	// Added, Modified and Deleted are the keys successfully changed.
+	Added    []string
+	Modified []string
+	Deleted  []string
+	Stream   bool
+}
+
+// ChangeReport is the report of the changes made by ApplyDesiredState.
+type ChangeReport struct {
+	// Upstreams are the results of the updates of the upstreams, HTTP upstreams first, by name.
+	Upstreams []UpstreamUpdateResult
+	// Keyvals are the changes of the keyval zones, HTTP zones first, by name.
+	Keyvals []KeyvalChanges
+	// Summary summarizes the updates of the upstreams.
+	Summary BatchSummary
+}
+
+// ApplyDesiredState reconciles the instance with the desired state. The servers of every upstream of the document
+// are updated like UpdateUpstreams, with the options. In every keyval zone of the document, the keys which are not
+// in the document are deleted, and the other keys are added or modified to have the values of the document.
+// An upstream without servers fails the reconciliation with ErrEmptyUpstream before any change is made,
+// unless the state allows empty upstreams.
+// The client will attempt to make all the changes, returning the report of the changes and all the errors that occurred.
+func (client *NginxClient) ApplyDesiredState(ctx context.Context, state *DesiredState, opts BatchOptions) (*ChangeReport, error) {
+	ctx = withOperation(ctx, Operation{Name: "ApplyDesiredState"})
+	if state == nil {
+		return nil, fmt.Errorf("failed to apply desired state: state: %w", ErrParameterRequired)
+	}
+	if err := state.checkEmptyUpstreams(); err != nil {
+		return nil, fmt.Errorf("failed to apply desired state: %w", err)
+	}
+	if err := client.checkWritable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to apply desired state: %w", err)
+	}
+
+	var updates []UpstreamUpdate
+	for _, upstream := range sortedKeys(state.HTTPUpstreams) {
+		updates = append(updates, UpstreamUpdate{Upstream: upstream, HTTPServers: state.HTTPUpstreams[upstream]})
+	}
+	for _, upstream := range sortedKeys(state.StreamUpstreams) {
+		updates = append(updates, UpstreamUpdate{Upstream: upstream, Stream: true, StreamServers: state.StreamUpstreams[upstream]})
+	}
+
+	report := &ChangeReport{}
+	batch, err := client.UpdateUpstreams(ctx, updates, opts)
+	report.Upstreams = batch.Results
+	report.Summary = batch.Summary
+
+	for _, zone := range sortedKeys(state.HTTPKeyvals) {
+		report.Keyvals = append(report.Keyvals, client.applyKeyvals(ctx, zone, state.HTTPKeyvals[zone], httpContext))
+	}
+	for _, zone := range sortedKeys(state.StreamKeyvals) {
+		report.Keyvals = append(report.Keyvals, client.applyKeyvals(ctx, zone, state.StreamKeyvals[zone], streamContext))
+	}
+	for _, changes := range report.Keyvals {
+		err = errors.Join(err, changes.Err)
+	}
+
+	if err != nil {
+		return report, fmt.Errorf("failed to apply desired state: %w", err)
+	}
+	return report, nil
+}
+
+// applyKeyvals makes the key-value pairs of the zone the pairs.
+func (client *NginxClient) applyKeyvals(ctx context.Context, zone string, pairs KeyValPairs, stream bool) KeyvalChanges {
+	changes := KeyvalChanges{Zone: zone, Stream: stream}
+	current, err := client.getKeyValPairs(ctx, zone, stream)
+	if err != nil {
+		changes.Err = err
+		return changes
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(pairs) {
+		value, exists := current[key]
+		switch {
+		case !exists:
+			err = client.addKeyValPair(ctx, zone, key, pairs[key], stream)
+			if err == nil {
+				changes.Added = append(changes.Added, key)
+			}
+		case value != pairs[key]:
+			err = client.modifyKeyValPair(ctx, zone, key, pairs[key], stream)
+			if err == nil {
+				changes.Modified = append(changes.Modified, key)
+			}
+		default:
+			continue
+		}
+		errs = append(errs, err)
+	}
+	for _, key := range sortedKeys(current) {
+		if _, ok := pairs[key]; ok {
+			continue
+		}
+		err = client.deleteKeyValuePair(ctx, zone, key, stream)
+		if err == nil {
+			changes.Deleted = append(changes.Deleted, key)
+		}
+		errs = append(errs, err)
+	}
+	changes.Err = errors.Join(errs...)
+	return changes
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseDesiredState(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{"http_upstreams":{"backend":[{"server":"10.0.0.1:80","weight":2}]},` +
+		`"stream_upstreams":{"dns":[{"server":"10.0.0.1:53"}]},"http_keyvals":{"limits":{"tenant1":"10r/s"}}}`)
+	state, err := ParseDesiredState(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	weight := 2
+	expected := &DesiredState{
+		HTTPUpstreams:   map[string][]UpstreamServer{"backend": {{Server: "10.0.0.1:80", Weight: &weight}}},
+		StreamUpstreams: map[string][]StreamUpstreamServer{"dns": {{Server: "10.0.0.1:53"}}},
+		HTTPKeyvals:     map[string]KeyValPairs{"limits": {"tenant1": "10r/s"}},
+	}
+	if !reflect.DeepEqual(state, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, state)
+	}
+
+	if _, err := ParseDesiredState([]byte(`{"http_upstream":{}}`)); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+
+	state, err = ParseDesiredStateWith(data, json.Unmarshal)
+	if err != nil || !reflect.DeepEqual(state, expected) {
+		t.Fatalf("expected %+v, got %+v, %v", expected, state, err)
+	}
+
+	// An unmarshal function which ignores the json tags leaves the document empty.
+	_, err = ParseDesiredStateWith(data, func(data []byte, v any) error {
+		var document map[string]any
+		return json.Unmarshal(data, &document)
+	})
+	if !errors.Is(err, ErrEmptyDesiredState) {
+		t.Fatalf("expected %v, got %v", ErrEmptyDesiredState, err)
+	}
+	if _, err := ParseDesiredState([]byte(`{}`)); !errors.Is(err, ErrEmptyDesiredState) {
+		t.Fatalf("expected %v, got %v", ErrEmptyDesiredState, err)
+	}
+
+	errUnmarshal := errors.New("bad document")
+	_, err = ParseDesiredStateWith(data, func([]byte, any) error { return errUnmarshal })
+	if !errors.Is(err, errUnmarshal) {
+		t.Fatalf("expected %v, got %v", errUnmarshal, err)
+	}
+}
+
+func TestApplyDesiredState(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodGet {
+			requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		}
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPatch:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/9/http/upstreams/backend/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:80"},{"id":1,"server":"10.0.0.2:80"}]`))
+		case r.URL.Path == "/9/http/keyvals/limits":
+			_, _ = w.Write([]byte(`{"tenant1":"5r/s","tenant2":"10r/s","tenant3":"1r/s"}`))
+		case r.URL.Path == "/9/stream/keyvals/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"keyval not found","code":"KeyvalNotFound"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.ApplyDesiredState(context.Background(), nil, BatchOptions{}); !errors.Is(err, ErrParameterRequired) {
+		t.Fatalf("expected %v, got %v", ErrParameterRequired, err)
+	}
+
+	// An upstream without servers is rejected before any change, unless the state allows it.
+	for _, empty := range []*DesiredState{
+		{HTTPUpstreams: map[string][]UpstreamServer{"backend": {}}},
+		{StreamUpstreams: map[string][]StreamUpstreamServer{"dns": nil}},
+	} {
+		if _, err := c.ApplyDesiredState(context.Background(), empty, BatchOptions{}); !errors.Is(err, ErrEmptyUpstream) {
+			t.Fatalf("expected %v, got %v", ErrEmptyUpstream, err)
+		}
+	}
+	if len(requests) != 0 {
+		t.Fatalf("expected no change for empty upstreams, got %v", requests)
+	}
+	report, err := c.ApplyDesiredState(context.Background(), &DesiredState{
+		HTTPUpstreams:       map[string][]UpstreamServer{"backend": nil},
+		AllowEmptyUpstreams: true,
+	}, BatchOptions{})
+	if err != nil || report.Summary.Deleted != 2 {
+		t.Fatalf("expected the servers of the empty upstream to be removed, got %+v, %v", report, err)
+	}
+	requests = nil
+
+	state := &DesiredState{
+		HTTPUpstreams: map[string][]UpstreamServer{"backend": {{Server: "10.0.0.1:80"}, {Server: "10.0.0.3:80"}}},
+		HTTPKeyvals:   map[string]KeyValPairs{"limits": {"tenant1": "10r/s", "tenant2": "10r/s", "tenant4": "2r/s"}},
+		StreamKeyvals: map[string]KeyValPairs{"missing": {}},
+	}
+	report, err = c.ApplyDesiredState(context.Background(), state, BatchOptions{})
+	if err == nil {
+		t.Fatal("expected an error for the missing keyval zone")
+	}
+
+	if len(report.Upstreams) != 1 || report.Upstreams[0].Err != nil || report.Summary.Added != 1 || report.Summary.Deleted != 1 {
+		t.Fatalf("unexpected upstream results: %+v", report)
+	}
+	expectedKeyvals := []KeyvalChanges{
+		{Zone: "limits", Added: []string{"tenant4"}, Modified: []string{"tenant1"}, Deleted: []string{"tenant3"}},
+		{Zone: "missing", Stream: true, Err: report.Keyvals[1].Err},
+	}
+	if !reflect.DeepEqual(report.Keyvals, expectedKeyvals) {
+		t.Fatalf("expected keyval changes %+v, got %+v", expectedKeyvals, report.Keyvals)
+	}
+	if report.Keyvals[1].Err == nil {
+		t.Fatal("expected an error for the missing keyval zone")
+	}
+
+	expectedRequests := map[string]bool{
+		`POST /9/http/upstreams/backend/servers/ {"server":"10.0.0.3:80"}`: true,
+		`DELETE /9/http/upstreams/backend/servers/1/ `:                     true,
+		`POST /9/http/keyvals/limits {"tenant4":"2r/s"}`:                   true,
+		`PATCH /9/http/keyvals/limits/ {"tenant1":"10r/s"}`:                true,
+		`PATCH /9/http/keyvals/limits/ {"tenant3":null}`:                   true,
+	}
+	if len(requests) != len(expectedRequests) {
+		t.Fatalf("expected requests %v, got %v", expectedRequests, requests)
+	}
+	for _, request := range requests {
+		if !expectedRequests[request] {
+			t.Errorf("unexpected request %v", request)
+		}
+	}
+}
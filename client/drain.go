@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// defaultDrainPollInterval is how often a draining server is polled if the poll interval is not set.
+const defaultDrainPollInterval = time.Second
+
+// DrainOptions configures how HTTP upstream servers are drained before they are removed.
+type DrainOptions struct {
+	// Progress, if not nil, is called every time the draining server is polled.
+	Progress func(DrainProgress)
+	// Timeout is how long to wait for the active connections of the server to reach zero.
+	// The server is removed once the timeout elapses. Zero means no timeout, only the context limits the wait.
+	Timeout time.Duration
+	// PollInterval is how often the server is polled. It defaults to one second.
+	PollInterval time.Duration
+}
+
+// DrainProgress describes a draining server, as observed when it was polled.
+type DrainProgress struct {
+	Upstream string
+	Server   string
+	// State is the state of the peer, normally "draining".
+	State string
+	// Elapsed is the time since the server was set to drain.
+	Elapsed time.Duration
+	// Active is the number of active connections of the server.
+	Active uint64
+	ID     int
+	// TimedOut is true if the timeout elapsed before the active connections reached zero.
+	TimedOut bool
+}
+
+// WithDrainOnDelete makes the client drain HTTP upstream servers before removing them, in DeleteHTTPServer,
+// UpdateHTTPServers and ApplyPlan. A server is set to drain, then polled until it has no active connections
+// or the timeout of the options elapses, and then removed.
+func WithDrainOnDelete(opts DrainOptions) Option {
+	return func(o *NginxClient) {
+		o.drain = &opts
+	}
+}
+
+// DrainAndDeleteHTTPServer sets the server of the upstream to drain, waits until it has no active connections
+// or the timeout of the options elapses, and then removes it from the upstream.
+// If the context is canceled while waiting, the server is left draining.
+func (client *NginxClient) DrainAndDeleteHTTPServer(ctx context.Context, upstream string, server string, opts DrainOptions) error {
+	ctx = withOperation(ctx, Operation{Name: "DrainAndDeleteHTTPServer", Upstream: upstream})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to remove %v server from %v upstream: %w", server, upstream, err)
+	}
+	id, err := client.getIDOfHTTPServer(ctx, upstream, server)
+	if err != nil {
+		return fmt.Errorf("failed to remove %v server from %v upstream: %w", server, upstream, err)
+	}
+	if id == -1 {
+		return fmt.Errorf("failed to remove %v server from %v upstream: %w", server, upstream, ErrServerNotFound)
+	}
+	return client.drainAndDeleteHTTPServer(ctx, upstream, server, id, opts)
+}
+
+func (client *NginxClient) drainAndDeleteHTTPServer(ctx context.Context, upstream, server string, serverID int, opts DrainOptions) error {
+	removed, err := client.drainHTTPServer(ctx, upstream, server, serverID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to drain %v server of %v upstream: %w", server, upstream, err)
+	}
+	if removed {
+		return nil
+	}
+	return client.removeHTTPServer(ctx, upstream, server, serverID)
+}
+
+// drainHTTPServer sets the server to drain and polls it until it has no active connections or the timeout elapses.
+// A server with the resolve parameter or a service has no peer of its own: the peers of the servers resolved from it
+// are polled instead, and their active connections added up.
+// It reports whether the server was removed from the upstream while draining.
+func (client *NginxClient) drainHTTPServer(ctx context.Context, upstream, server string, serverID int, opts DrainOptions) (bool, error) {
+	path := fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, serverID)
+	err := client.patch(ctx, path, map[string]bool{"drain": true}, http.StatusOK)
+	if err != nil {
+		return false, err
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultDrainPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		u, err := client.GetUpstreamWithFields(ctx, upstream, "peers")
+		if err != nil {
+			return false, err
+		}
+
+		// The resolved servers change with DNS, so they are read again at every poll.
+		resolved := newHTTPServerPeers(ctx, client, upstream, u.Peers, peerID)
+		peers, err := resolved.peersOf(serverID)
+		if err != nil {
+			return false, err
+		}
+		if len(peers) == 0 {
+			// The server was removed, or it is resolved and has no resolved servers, so no connections.
+			servers, err := resolved.upstreamServers()
+			if err != nil {
+				return false, err
+			}
+			return !slices.ContainsFunc(servers, func(s UpstreamServer) bool { return s.ID == serverID }), nil
+		}
+
+		var active uint64
+		for _, peer := range peers {
+			active += peer.Active
+		}
+		elapsed := time.Since(start)
+		progress := DrainProgress{
+			Upstream: upstream,
+			Server:   server,
+			ID:       serverID,
+			State:    peers[0].State,
+			Active:   active,
+			Elapsed:  elapsed,
+			TimedOut: active > 0 && opts.Timeout > 0 && elapsed >= opts.Timeout,
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		if active == 0 || progress.TimedOut {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("server left draining with %v active connections: %w", active, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// resetHTTPServer updates the server of the upstream to the parameters of the server. Its drain and down parameters
+// are sent even when they are false, which UpdateHTTPServer omits, so that a server set to drain or down gets them back.
+func (client *NginxClient) resetHTTPServer(ctx context.Context, upstream string, server UpstreamServer) error {
+	path := fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, server.ID)
+	server.ID = 0
+	server.Parent = nil
+	server.Host = ""
+	server.Resolve = false
+	down := server.Down != nil && *server.Down
+	body := struct {
+		UpstreamServer
+		Down  bool `json:"down"`
+		Drain bool `json:"drain"`
+	}{UpstreamServer: server, Down: down, Drain: server.Drain}
+	err := client.patch(ctx, path, &body, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to reset %v server of %v upstream: %w", server.Server, upstream, err)
+	}
+	return nil
+}
+
+// serverPeers finds the peers of the servers of an upstream. A server with resolve or service has no peer of its own,
+// so its peers are those of the servers resolved from it, found in the servers of the upstream, which are read once if needed.
+type serverPeers[P, S any] struct {
+	fetch    func() ([]S, error)
+	peerID   func(P) int
+	serverID func(S) int
+	parentID func(S) *int
+	peers    []P
+	servers  []S
+	fetched  bool
+}
+
+// newHTTPServerPeers returns the finder of the peers of the servers of the HTTP upstream.
+func newHTTPServerPeers[P any](ctx context.Context, client *NginxClient, upstream string, peers []P, peerID func(P) int) *serverPeers[P, UpstreamServer] {
+	return &serverPeers[P, UpstreamServer]{
+		fetch:    func() ([]UpstreamServer, error) { return client.GetHTTPServers(ctx, upstream) },
+		peerID:   peerID,
+		serverID: httpServerID,
+		parentID: httpServerParent,
+		peers:    peers,
+	}
+}
+
+// newStreamServerPeers returns the finder of the peers of the servers of the stream upstream.
+func newStreamServerPeers[P any](ctx context.Context, client *NginxClient, upstream string, peers []P, peerID func(P) int) *serverPeers[P, StreamUpstreamServer] {
+	return &serverPeers[P, StreamUpstreamServer]{
+		fetch:    func() ([]StreamUpstreamServer, error) { return client.GetStreamServers(ctx, upstream) },
+		peerID:   peerID,
+		serverID: streamServerID,
+		parentID: streamServerParent,
+		peers:    peers,
+	}
+}
+
+// peersOf returns the peers of the server with the ID, or of the servers resolved from it.
+// It returns no peers if the server was removed or has no resolved servers.
+func (sp *serverPeers[P, S]) peersOf(id int) ([]P, error) {
+	if i := slices.IndexFunc(sp.peers, func(p P) bool { return sp.peerID(p) == id }); i != -1 {
+		return sp.peers[i : i+1], nil
+	}
+	servers, err := sp.upstreamServers()
+	if err != nil {
+		return nil, err
+	}
+	ids := resolvedIDs(servers, id, sp.serverID, sp.parentID)
+	var peers []P
+	for _, peer := range sp.peers {
+		if slices.Contains(ids, sp.peerID(peer)) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
+
+// upstreamServers returns the servers of the upstream, reading them the first time.
+func (sp *serverPeers[P, S]) upstreamServers() ([]S, error) {
+	if !sp.fetched {
+		servers, err := sp.fetch()
+		if err != nil {
+			return nil, err
+		}
+		sp.servers, sp.fetched = servers, true
+	}
+	return sp.servers, nil
+}
+
+func peerID(p Peer) int { return p.ID }
+
+func peerStateID(p PeerState) int { return p.ID }
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrainOnDelete(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []string
+	active := 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[{"id":3,"server":"127.0.0.1:80"}]`))
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"peers":[{"id":3,"server":"127.0.0.1:80","state":"draining","active":` + strconv.Itoa(active) + `}]}`))
+			active--
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	var progress []DrainProgress
+	c, err := NewNginxClient(ts.URL, WithDrainOnDelete(DrainOptions{
+		PollInterval: time.Millisecond,
+		Progress: func(p DrainProgress) {
+			p.Elapsed = 0
+			progress = append(progress, p)
+		},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = c.DeleteHTTPServer(context.Background(), "test", "127.0.0.1:80")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedRequests := []string{
+		"GET /9/http/upstreams/test/servers ",
+		"PATCH /9/http/upstreams/test/servers/3/ " + `{"drain":true}`,
+		"GET /9/http/upstreams/test ",
+		"GET /9/http/upstreams/test ",
+		"GET /9/http/upstreams/test ",
+		"DELETE /9/http/upstreams/test/servers/3/ ",
+	}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Fatalf("expected requests %v, got %v", expectedRequests, requests)
+	}
+
+	expectedProgress := []DrainProgress{
+		{Upstream: "test", Server: "127.0.0.1:80", ID: 3, State: "draining", Active: 2},
+		{Upstream: "test", Server: "127.0.0.1:80", ID: 3, State: "draining", Active: 1},
+		{Upstream: "test", Server: "127.0.0.1:80", ID: 3, State: "draining", Active: 0},
+	}
+	if !reflect.DeepEqual(progress, expectedProgress) {
+		t.Fatalf("expected progress %+v, got %+v", expectedProgress, progress)
+	}
+}
+
+func TestDrainAndDeleteHTTPServerTimeout(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	deleted := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[{"id":3,"server":"127.0.0.1:80"}]`))
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"peers":[{"id":3,"server":"127.0.0.1:80","state":"draining","active":5}]}`))
+		case r.Method == http.MethodDelete:
+			deleted = true
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var last DrainProgress
+	err = c.DrainAndDeleteHTTPServer(context.Background(), "test", "127.0.0.1:80", DrainOptions{
+		Timeout:      20 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+		Progress:     func(p DrainProgress) { last = p },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !last.TimedOut || !deleted {
+		t.Fatalf("expected the server to be removed after the timeout, got %+v", last)
+	}
+
+	deleted = false
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = c.DrainAndDeleteHTTPServer(ctx, "test", "127.0.0.1:80", DrainOptions{PollInterval: 5 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if deleted {
+		t.Fatal("expected the server not to be removed after the context is canceled")
+	}
+}
+
+func TestDrainOnDeleteResolvedServer(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []string
+	active := 3
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[{"id":1,"server":"backend.example.com:80","resolve":true},` +
+				`{"id":2,"server":"10.0.0.1:80","parent":1,"host":"backend.example.com:80"},` +
+				`{"id":3,"server":"10.0.0.2:80","parent":1,"host":"backend.example.com:80"}]`))
+		case r.Method == http.MethodGet:
+			// The peers of the servers resolved from the server drain one connection after the other.
+			_, _ = w.Write([]byte(`{"peers":[{"id":2,"server":"10.0.0.1:80","state":"draining","active":` + strconv.Itoa(max(active-1, 0)) + `},` +
+				`{"id":3,"server":"10.0.0.2:80","state":"draining","active":` + strconv.Itoa(min(active, 1)) + `}]}`))
+			active--
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	var progress []uint64
+	c, err := NewNginxClient(ts.URL, WithDrainOnDelete(DrainOptions{
+		PollInterval: time.Millisecond,
+		Progress:     func(p DrainProgress) { progress = append(progress, p.Active) },
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, deleted, _, err := c.UpdateHTTPServers(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != 1 {
+		t.Fatalf("expected the resolved server to be deleted, got %+v", deleted)
+	}
+
+	if !reflect.DeepEqual(progress, []uint64{3, 2, 1, 0}) {
+		t.Fatalf("expected the active connections of the resolved servers to be added up, got %v", progress)
+	}
+	if last := requests[len(requests)-1]; last != "DELETE /9/http/upstreams/test/servers/1/" {
+		t.Fatalf("expected the resolved server to be removed once drained, got %v", requests)
+	}
+}
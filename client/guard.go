@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsafeUpdate is wrapped by the UnsafeUpdateError returned when the safety guard refuses an update.
+var ErrUnsafeUpdate = errors.New("update refused by the safety guard")
+
+// SafetyGuard limits the changes made to the servers of an upstream in one call, protecting the upstream
+// against a faulty list of servers. A zero value disables the corresponding check.
+//
+// Healthy servers are the servers which are not backup servers and whose peers are in the "up" state.
+// The servers remaining healthy after an update are the healthy servers which are neither deleted
+// nor updated to be down, backup or draining. The servers resolved by NGINX from a server follow its changes.
+// Added servers are not counted, as their health is unknown.
+type SafetyGuard struct {
+	// MinHealthy is the minimum number of servers which must remain healthy.
+	MinHealthy int
+	// MinHealthyPercent is the minimum percentage, from 0 to 100, of the currently healthy servers which must remain healthy.
+	MinHealthyPercent float64
+	// MaxDeleteFraction is the maximum fraction, from 0 to 1, of the servers of the upstream which may be deleted.
+	MaxDeleteFraction float64
+}
+
+// UnsafeUpdateError is returned when the safety guard refuses an update. It includes the plan of the refused changes.
+type UnsafeUpdateError struct {
+	Plan   *ServersPlan
+	Reason string
+	// Servers is the number of servers of the upstream, not counting the servers resolved by NGINX.
+	Servers int
+	// Healthy is the number of healthy servers.
+	Healthy int
+	// RemainingHealthy is the number of servers which would remain healthy after the update.
+	RemainingHealthy int
+}
+
+func (e *UnsafeUpdateError) Error() string {
+	return fmt.Sprintf("%v of %v upstream: %v: %v servers, %v healthy, %v would remain healthy, %v to add, %v to delete, %v to update",
+		ErrUnsafeUpdate, e.Plan.Upstream, e.Reason, e.Servers, e.Healthy, e.RemainingHealthy,
+		len(e.Plan.Add), len(e.Plan.Delete), len(e.Plan.Update))
+}
+
+// Unwrap returns ErrUnsafeUpdate.
+func (e *UnsafeUpdateError) Unwrap() error {
+	return ErrUnsafeUpdate
+}
+
+// WithSafetyGuard makes UpdateHTTPServers, UpdateStreamServers and ApplyPlan refuse, without making any change,
+// updates which don't satisfy the guard, returning an *UnsafeUpdateError.
+func WithSafetyGuard(guard SafetyGuard) Option {
+	return func(o *NginxClient) {
+		o.guard = &guard
+	}
+}
+
+// peerHealth is the health of a peer, used by the safety guard.
+type peerHealth struct {
+	id      int
+	healthy bool
+}
+
+// checkGuard returns an *UnsafeUpdateError if the plan for the upstream with the number of servers doesn't satisfy the guard.
+func (client *NginxClient) checkGuard(ctx context.Context, plan *ServersPlan, servers int) error {
+	guard := client.guard
+	if guard == nil || plan.IsEmpty() {
+		return nil
+	}
+
+	var peers []peerHealth
+	if plan.Stream {
+		u, err := client.GetStreamUpstreamWithFields(ctx, plan.Upstream, "peers")
+		if err != nil {
+			return fmt.Errorf("failed to get peers for the safety guard: %w", err)
+		}
+		for _, peer := range u.Peers {
+			peers = append(peers, peerHealth{id: peer.ID, healthy: !peer.Backup && peer.State == "up"})
+		}
+	} else {
+		u, err := client.GetUpstreamWithFields(ctx, plan.Upstream, "peers")
+		if err != nil {
+			return fmt.Errorf("failed to get peers for the safety guard: %w", err)
+		}
+		for _, peer := range u.Peers {
+			peers = append(peers, peerHealth{id: peer.ID, healthy: !peer.Backup && peer.State == "up"})
+		}
+	}
+
+	unhealthy := map[int]bool{}
+	for _, change := range plan.Delete {
+		unhealthy[change.ID] = true
+		for _, id := range change.Resolved {
+			unhealthy[id] = true
+		}
+	}
+	for _, change := range plan.Update {
+		if change.HTTPServer != nil {
+			s := change.HTTPServer
+			unhealthy[change.ID] = (s.Down != nil && *s.Down) || (s.Backup != nil && *s.Backup) || s.Drain
+		}
+		if change.StreamServer != nil {
+			s := change.StreamServer
+			unhealthy[change.ID] = (s.Down != nil && *s.Down) || (s.Backup != nil && *s.Backup)
+		}
+		for _, id := range change.Resolved {
+			unhealthy[id] = unhealthy[change.ID]
+		}
+	}
+
+	unsafe := &UnsafeUpdateError{Plan: plan, Servers: servers}
+	for _, peer := range peers {
+		if !peer.healthy {
+			continue
+		}
+		unsafe.Healthy++
+		if !unhealthy[peer.id] {
+			unsafe.RemainingHealthy++
+		}
+	}
+
+	switch {
+	case guard.MaxDeleteFraction > 0 && servers > 0 && float64(len(plan.Delete))/float64(servers) > guard.MaxDeleteFraction:
+		unsafe.Reason = fmt.Sprintf("more than %v of the servers would be deleted", guard.MaxDeleteFraction)
+	case unsafe.RemainingHealthy >= unsafe.Healthy:
+		return nil
+	case unsafe.RemainingHealthy < guard.MinHealthy:
+		unsafe.Reason = fmt.Sprintf("fewer than %v servers would remain healthy", guard.MinHealthy)
+	case float64(unsafe.RemainingHealthy)*100 < guard.MinHealthyPercent*float64(unsafe.Healthy):
+		unsafe.Reason = fmt.Sprintf("fewer than %v%% of the healthy servers would remain healthy", guard.MinHealthyPercent)
+	default:
+		return nil
+	}
+	return unsafe
+}
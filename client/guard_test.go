@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSafetyGuard(t *testing.T) {
+	t.Parallel()
+
+	var writes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/9/http/upstreams/test/servers", "/9/stream/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[{"id":1,"server":"10.0.0.1:80"},{"id":2,"server":"10.0.0.2:80"},` +
+				`{"id":3,"server":"10.0.0.3:80"},{"id":4,"server":"10.0.0.4:80","backup":true}]`))
+		case "/9/http/upstreams/test", "/9/stream/upstreams/test":
+			_, _ = w.Write([]byte(`{"peers":[{"id":1,"state":"up"},{"id":2,"state":"up"},` +
+				`{"id":3,"state":"unhealthy"},{"id":4,"state":"up","backup":true}]}`))
+		default:
+			writes.Add(1)
+			switch r.Method {
+			case http.MethodPost:
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	down := true
+	tests := []struct {
+		name        string
+		guard       SafetyGuard
+		httpServers []UpstreamServer
+		unsafe      bool
+	}{
+		{
+			name:        "min healthy",
+			guard:       SafetyGuard{MinHealthy: 2},
+			httpServers: []UpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.3:80"}, {Server: "10.0.0.4:80"}, {Server: "10.0.0.5:80"}},
+			unsafe:      true,
+		},
+		{
+			name:  "min healthy percent",
+			guard: SafetyGuard{MinHealthyPercent: 60},
+			httpServers: []UpstreamServer{
+				{Server: "10.0.0.1:80", Down: &down}, {Server: "10.0.0.2:80"}, {Server: "10.0.0.3:80"}, {Server: "10.0.0.4:80"},
+			},
+			unsafe: true,
+		},
+		{
+			name:        "max delete fraction",
+			guard:       SafetyGuard{MaxDeleteFraction: 0.25},
+			httpServers: []UpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.2:80"}},
+			unsafe:      true,
+		},
+		{
+			name:        "unhealthy and backup servers deleted",
+			guard:       SafetyGuard{MinHealthy: 2, MinHealthyPercent: 100, MaxDeleteFraction: 0.5},
+			httpServers: []UpstreamServer{{Server: "10.0.0.1:80"}, {Server: "10.0.0.2:80"}},
+			unsafe:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewNginxClient(ts.URL, WithSafetyGuard(tt.guard))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, _, _, err = c.UpdateHTTPServers(context.Background(), "test", tt.httpServers)
+			var unsafe *UnsafeUpdateError
+			if !tt.unsafe {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &unsafe) || !errors.Is(err, ErrUnsafeUpdate) {
+				t.Fatalf("expected %v, got %v", ErrUnsafeUpdate, err)
+			}
+			if unsafe.Plan == nil || unsafe.Plan.Upstream != "test" || unsafe.Healthy != 2 {
+				t.Fatalf("unexpected error: %+v", unsafe)
+			}
+
+			var streamServers []StreamUpstreamServer
+			for _, server := range tt.httpServers {
+				streamServers = append(streamServers, StreamUpstreamServer{Server: server.Server, Down: server.Down})
+			}
+			_, _, _, err = c.UpdateStreamServers(context.Background(), "test", streamServers)
+			if !errors.As(err, &unsafe) || !unsafe.Plan.Stream {
+				t.Fatalf("expected %v for the stream upstream, got %v", ErrUnsafeUpdate, err)
+			}
+		})
+	}
+
+	t.Cleanup(func() {
+		if writes.Load() != 2 {
+			t.Errorf("expected only the safe update to modify the upstream, got %v requests", writes.Load())
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// WithLogger sets the logger used to log the API requests sent by the client.
+// Successful GET requests are logged at the debug level, other successful requests at the info level
+// and failed requests at the warn level, including the error returned by the API.
+// Errors which are expected outcomes, such as a missing stream endpoint or a server already removed by
+// a concurrent writer when conflict retries are enabled, are logged at the debug level.
+// The request of IsWritable probing whether the API is writable is logged at the debug level, as it is expected to be rejected.
+// The changes determined by UpdateHTTPServers and UpdateStreamServers are logged at the debug level,
+// and the conflicts with concurrent writers at the info level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *NginxClient) {
+		o.logger = logger
+	}
+}
+
+// logRequests is the middleware that logs API requests.
+func (client *NginxClient) logRequests(next RequestHandler) RequestHandler {
+	return func(op Operation, req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(op, req)
+
+		attrs := []slog.Attr{
+			slog.String("operation", op.Name),
+			slog.String("method", op.Method),
+			slog.String("path", op.Path),
+			slog.Int("api_version", client.apiVersion),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if op.Upstream != "" {
+			attrs = append(attrs, slog.String("upstream", op.Upstream))
+		}
+		if op.Zone != "" {
+			attrs = append(attrs, slog.String("zone", op.Zone))
+		}
+
+		ctx := req.Context()
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+			client.logger.LogAttrs(ctx, slog.LevelWarn, "NGINX Plus API request failed", attrs...)
+			return resp, err
+		}
+
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if op.Method == http.MethodPost && op.Path == writableProbePath {
+			client.logger.LogAttrs(ctx, slog.LevelDebug, "NGINX Plus API request", attrs...)
+			return resp, nil
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			errAttrs, expected := apiErrorAttrs(ctx, resp)
+			level := slog.LevelWarn
+			if expected {
+				level = slog.LevelDebug
+			}
+			client.logger.LogAttrs(ctx, level, "NGINX Plus API request failed", append(attrs, errAttrs...)...)
+			return resp, nil
+		}
+
+		level := slog.LevelInfo
+		if req.Method == http.MethodGet {
+			level = slog.LevelDebug
+		}
+		client.logger.LogAttrs(ctx, level, "NGINX Plus API request", attrs...)
+		return resp, nil
+	}
+}
+
+// apiErrorAttrs returns the fields of the error returned by the API, and whether the error is expected by the caller.
+// The body of the response is restored, so the caller can read it again.
+func apiErrorAttrs(ctx context.Context, resp *http.Response) ([]slog.Attr, bool) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+
+	var apiErr apiErrorResponse
+	if json.Unmarshal(body, &apiErr) != nil {
+		return nil, false
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", apiErr.RequestID),
+		slog.String("href", apiErr.Href),
+		slog.Group("error",
+			slog.String("code", apiErr.Error.Code),
+			slog.String("text", apiErr.Error.Text),
+		),
+	}
+	return attrs, isExpectedError(ctx, &APIError{Status: resp.StatusCode, Code: apiErr.Error.Code})
+}
+
+type expectedErrorsKey struct{}
+
+// expectingErrors returns a context in which the API errors matching the targets are expected outcomes
+// handled by the caller, so that they are not logged as failures.
+func expectingErrors(ctx context.Context, targets ...error) context.Context {
+	expected, _ := ctx.Value(expectedErrorsKey{}).([]error)
+	return context.WithValue(ctx, expectedErrorsKey{}, slices.Concat(expected, targets))
+}
+
+// isExpectedError reports whether the API error is expected in the context.
+func isExpectedError(ctx context.Context, apiErr *APIError) bool {
+	targets, _ := ctx.Value(expectedErrorsKey{}).([]error)
+	return slices.ContainsFunc(targets, func(target error) bool { return errors.Is(apiErr, target) })
+}
+
+// logUpdates logs the changes determined for the servers of an upstream.
+func (client *NginxClient) logUpdates(ctx context.Context, upstream string, toAdd, toDelete, toUpdate []string) {
+	if client.logger == nil {
+		return
+	}
+	client.logger.DebugContext(ctx, "determined updates of upstream servers",
+		slog.String("upstream", upstream),
+		slog.Any("add", toAdd),
+		slog.Any("delete", toDelete),
+		slog.Any("update", toUpdate),
+	)
+}
+
+// logConflict logs a conflict with a concurrent writer, before the changes to the servers of an upstream are retried.
+func (client *NginxClient) logConflict(ctx context.Context, upstream string, retry int, err error) {
+	if client.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{slog.String("upstream", upstream), slog.Int("retry", retry)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	client.logger.LogAttrs(ctx, slog.LevelInfo, "upstream servers changed concurrently, retrying", attrs...)
+}
+
+func upstreamServerAddresses(servers []UpstreamServer) []string {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		addresses = append(addresses, server.Server)
+	}
+	return addresses
+}
+
+func streamUpstreamServerAddresses(servers []StreamUpstreamServer) []string {
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		addresses = append(addresses, server.Server)
+	}
+	return addresses
+}
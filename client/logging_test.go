@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to unmarshal log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`[{"id":1,"server":"127.0.0.2:80"}]`))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"status":400,"text":"invalid server","code":"UpstreamBadAddress"},"request_id":"abc","href":"https://nginx.org/en/docs/http/ngx_http_api_module.html"}`))
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	c, err := NewNginxClient(ts.URL, WithAPIVersion(8), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, _, err = c.UpdateHTTPServers(context.Background(), "test", []UpstreamServer{{Server: "127.0.0.1"}})
+	if err == nil {
+		t.Fatal("expected error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "UpstreamBadAddress") {
+		t.Fatalf("expected the error returned by the API to be preserved, got %v", err)
+	}
+
+	records := buf.records(t)
+	if len(records) != 4 {
+		t.Fatalf("expected 4 log records, got %v: %v", len(records), records)
+	}
+
+	get := records[0]
+	if get["level"] != "DEBUG" || get["method"] != http.MethodGet || get["path"] != "http/upstreams/test/servers" ||
+		get["status"] != float64(http.StatusOK) || get["api_version"] != float64(8) ||
+		get["operation"] != "UpdateHTTPServers" || get["upstream"] != "test" {
+		t.Fatalf("unexpected log record for the GET request: %v", get)
+	}
+	if _, ok := get["duration"]; !ok {
+		t.Fatalf("expected the log record to contain the duration: %v", get)
+	}
+
+	updates := records[1]
+	if updates["level"] != "DEBUG" ||
+		!reflect.DeepEqual(updates["add"], []interface{}{"127.0.0.1:80"}) ||
+		!reflect.DeepEqual(updates["delete"], []interface{}{"127.0.0.2:80"}) ||
+		!reflect.DeepEqual(updates["update"], []interface{}{}) {
+		t.Fatalf("unexpected log record for the updates: %v", updates)
+	}
+
+	post := records[2]
+	expectedError := map[string]interface{}{"code": "UpstreamBadAddress", "text": "invalid server"}
+	if post["level"] != "WARN" || post["status"] != float64(http.StatusBadRequest) || post["request_id"] != "abc" ||
+		post["href"] != "https://nginx.org/en/docs/http/ngx_http_api_module.html" || !reflect.DeepEqual(post["error"], expectedError) {
+		t.Fatalf("unexpected log record for the POST request: %v", post)
+	}
+
+	deleteRecord := records[3]
+	if deleteRecord["level"] != "INFO" || deleteRecord["method"] != http.MethodDelete || deleteRecord["path"] != "http/upstreams/test/servers/1" {
+		t.Fatalf("unexpected log record for the DELETE request: %v", deleteRecord)
+	}
+}
+
+func TestWithLoggerExpectedErrors(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	removed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodDelete:
+			// Another writer removed the server in between.
+			removed = true
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"server not found","code":"UpstreamServerNotFound"}}`))
+		case r.URL.Path == "/9/http/upstreams/test/servers" && !removed:
+			_, _ = w.Write([]byte(`[{"id":1,"server":"127.0.0.2:80"}]`))
+		case r.URL.Path == "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"path not found","code":"PathNotFound"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	c, err := NewNginxClient(ts.URL, WithLogger(logger), WithConflictRetries(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, _, err = c.UpdateHTTPServers(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = c.GetStreamUpstreams(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var failures int
+	for _, record := range buf.records(t) {
+		if record["status"] != float64(http.StatusNotFound) {
+			continue
+		}
+		failures++
+		if record["level"] != "DEBUG" {
+			t.Errorf("expected the expected error to be logged at the debug level: %v", record)
+		}
+	}
+	if failures != 2 {
+		t.Fatalf("expected 2 log records of expected errors, got %v", failures)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HostMatcher matches the servers of a backend host, by their address and port.
+type HostMatcher struct {
+	// Host is the IP address or the hostname of the servers, as configured in the upstreams.
+	Host string
+	// MinPort and MaxPort limit the servers to a range of ports. Zero means no limit.
+	MinPort int
+	MaxPort int
+}
+
+// matches reports whether the server address, such as "10.0.0.1:80" or "backend.example.com:8080", belongs to the host.
+// Servers resolved by NGINX from a hostname are not changed on their own, so they match only through their parent.
+func (m HostMatcher) matches(server string) bool {
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		// Servers with a service have no port.
+		host, portStr = server, ""
+	}
+	if !strings.EqualFold(host, strings.Trim(m.Host, "[]")) {
+		return false
+	}
+	if m.MinPort == 0 && m.MaxPort == 0 {
+		return true
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return false
+	}
+	return (m.MinPort == 0 || port >= m.MinPort) && (m.MaxPort == 0 || port <= m.MaxPort)
+}
+
+// Maintenance records the servers of a host changed by StartMaintenance, with their parameters before the maintenance,
+// so that EndMaintenance can restore them. It can be serialized to JSON, for example to end the maintenance from another process.
+type Maintenance struct {
+	Host    HostMatcher         `json:"host"`
+	Servers []MaintenanceServer `json:"servers,omitempty"`
+	Drain   bool                `json:"drain,omitempty"`
+}
+
+// MaintenanceServer is a server changed by StartMaintenance.
+type MaintenanceServer struct {
+	// HTTPServer is the server of an HTTP upstream, with its parameters before the maintenance.
+	HTTPServer *UpstreamServer `json:"http_server,omitempty"`
+	// StreamServer is the server of a stream upstream, with its parameters before the maintenance.
+	StreamServer *StreamUpstreamServer `json:"stream_server,omitempty"`
+	Upstream     string                `json:"upstream"`
+	Stream       bool                  `json:"stream,omitempty"`
+}
+
+// StartMaintenance takes the servers of the host out of all the HTTP and stream upstreams. The servers are set down,
+// or, if drain is true, the servers of HTTP upstreams are set to drain, while the servers of stream upstreams, which can't drain, are set down.
+// It returns the record of the changed servers, with their parameters before the maintenance, for EndMaintenance.
+// The client will attempt to change all the servers, returning the record of the changed servers and all the errors that occurred.
+func (client *NginxClient) StartMaintenance(ctx context.Context, host HostMatcher, drain bool) (*Maintenance, error) {
+	ctx = withOperation(ctx, Operation{Name: "StartMaintenance"})
+	if host.Host == "" {
+		return nil, fmt.Errorf("failed to start maintenance: host: %w", ErrParameterRequired)
+	}
+	if err := client.checkWritable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start maintenance of %v: %w", host.Host, err)
+	}
+
+	maintenance := &Maintenance{Host: host, Drain: drain}
+	servers, err := client.hostServers(ctx, host)
+	if err != nil {
+		return maintenance, fmt.Errorf("failed to start maintenance of %v: %w", host.Host, err)
+	}
+
+	changed, err := applyUpdates(client.updateConcurrency, servers, func(server MaintenanceServer) error {
+		down := true
+		if server.Stream {
+			s := *server.StreamServer
+			s.Down = &down
+			return client.UpdateStreamServer(ctx, server.Upstream, s)
+		}
+		s := *server.HTTPServer
+		if drain {
+			s.Drain = true
+		} else {
+			s.Down = &down
+		}
+		return client.UpdateHTTPServer(ctx, server.Upstream, s)
+	})
+	maintenance.Servers = changed
+	if err != nil {
+		return maintenance, fmt.Errorf("failed to start maintenance of %v: %w", host.Host, err)
+	}
+	return maintenance, nil
+}
+
+// hostServers returns the configured servers of the host in all the HTTP and stream upstreams.
+func (client *NginxClient) hostServers(ctx context.Context, host HostMatcher) ([]MaintenanceServer, error) {
+	upstreams, err := client.GetUpstreamsWithFields(ctx, "zone")
+	if err != nil {
+		return nil, err
+	}
+	streamUpstreams, err := client.GetStreamUpstreamsWithFields(ctx, "zone")
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []MaintenanceServer
+	for _, upstream := range sortedKeys(*upstreams) {
+		servers, err := client.GetHTTPServers(ctx, upstream)
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range configuredServers(servers) {
+			if host.matches(server.Server) {
+				matched = append(matched, MaintenanceServer{Upstream: upstream, HTTPServer: &server})
+			}
+		}
+	}
+	for _, upstream := range sortedKeys(*streamUpstreams) {
+		servers, err := client.GetStreamServers(ctx, upstream)
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range configuredStreamServers(servers) {
+			if host.matches(server.Server) {
+				matched = append(matched, MaintenanceServer{Upstream: upstream, Stream: true, StreamServer: &server})
+			}
+		}
+	}
+	return matched, nil
+}
+
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// EndMaintenance restores the servers changed by StartMaintenance to their parameters before the maintenance.
+// The drain and down parameters of the HTTP servers are sent explicitly, so that draining servers stop draining.
+// A server re-created during the maintenance, with another ID, is found by its address.
+// The client will attempt to restore all the servers, returning all the errors that occurred.
+func (client *NginxClient) EndMaintenance(ctx context.Context, maintenance *Maintenance) error {
+	ctx = withOperation(ctx, Operation{Name: "EndMaintenance"})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to end maintenance of %v: %w", maintenance.Host.Host, err)
+	}
+
+	_, err := applyUpdates(client.updateConcurrency, maintenance.Servers, func(server MaintenanceServer) error {
+		if server.Stream {
+			return client.restoreStreamServer(ctx, server.Upstream, *server.StreamServer)
+		}
+		return client.restoreHTTPServer(ctx, server.Upstream, *server.HTTPServer)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to end maintenance of %v: %w", maintenance.Host.Host, err)
+	}
+	return nil
+}
+
+// restoreHTTPServer restores the parameters of the server, including its drain and down parameters.
+func (client *NginxClient) restoreHTTPServer(ctx context.Context, upstream string, server UpstreamServer) error {
+	err := client.resetHTTPServer(expectingErrors(ctx, ErrServerNotFound), upstream, server)
+	if !errors.Is(err, ErrServerNotFound) {
+		return err
+	}
+	id, idErr := client.getIDOfHTTPServer(ctx, upstream, server.Server)
+	if idErr != nil || id == -1 {
+		return errors.Join(err, idErr)
+	}
+	server.ID = id
+	return client.resetHTTPServer(ctx, upstream, server)
+}
+
+// restoreStreamServer restores the parameters of the stream server.
+func (client *NginxClient) restoreStreamServer(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+	if server.Down == nil {
+		server.Down = &defaultDown
+	}
+	err := client.UpdateStreamServer(expectingErrors(ctx, ErrServerNotFound), upstream, server)
+	if !errors.Is(err, ErrServerNotFound) {
+		return err
+	}
+	id, idErr := client.getIDOfStreamServer(ctx, upstream, server.Server)
+	if idErr != nil || id == -1 {
+		return errors.Join(err, idErr)
+	}
+	server.ID = id
+	return client.UpdateStreamServer(ctx, upstream, server)
+}
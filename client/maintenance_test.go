@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var patches []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPatch {
+			body, _ := io.ReadAll(r.Body)
+			patches = append(patches, r.URL.Path+" "+string(body))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/9/http/upstreams":
+			_, _ = w.Write([]byte(`{"b":{"zone":"b"},"a":{"zone":"a"}}`))
+		case "/9/stream/upstreams":
+			_, _ = w.Write([]byte(`{"c":{"zone":"c"}}`))
+		case "/9/http/upstreams/a/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:80","weight":1,"down":false},{"id":1,"server":"10.0.0.2:80"}]`))
+		case "/9/http/upstreams/b/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:8080"},{"id":1,"server":"10.0.0.1:85","weight":2,"down":true},` +
+				`{"id":2,"server":"10.0.0.1:90","parent":0,"host":"backend.example.com:90"}]`))
+		case "/9/stream/upstreams/c/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:81","weight":1,"down":false}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	maintenance, err := c.StartMaintenance(context.Background(), HostMatcher{Host: "10.0.0.1", MinPort: 80, MaxPort: 90}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedPatches := []string{
+		`/9/http/upstreams/a/servers/0/ {"down":false,"weight":1,"server":"10.0.0.1:80","drain":true}`,
+		`/9/http/upstreams/b/servers/1/ {"down":true,"weight":2,"server":"10.0.0.1:85","drain":true}`,
+		`/9/stream/upstreams/c/servers/0/ {"down":true,"weight":1,"server":"10.0.0.1:81"}`,
+	}
+	if !reflect.DeepEqual(patches, expectedPatches) {
+		t.Fatalf("expected patches %v, got %v", expectedPatches, patches)
+	}
+
+	// The record can be persisted to end the maintenance later.
+	data, err := json.Marshal(maintenance)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var restored Maintenance
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	patches = nil
+	err = c.EndMaintenance(context.Background(), &restored)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The drain and down parameters of the HTTP servers are restored explicitly.
+	expectedPatches = []string{
+		`/9/http/upstreams/a/servers/0/ {"weight":1,"server":"10.0.0.1:80","down":false,"drain":false}`,
+		`/9/http/upstreams/b/servers/1/ {"weight":2,"server":"10.0.0.1:85","down":true,"drain":false}`,
+		`/9/stream/upstreams/c/servers/0/ {"down":false,"weight":1,"server":"10.0.0.1:81"}`,
+	}
+	if !reflect.DeepEqual(patches, expectedPatches) {
+		t.Fatalf("expected patches %v, got %v", expectedPatches, patches)
+	}
+}
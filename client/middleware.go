@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Operation describes the client method on behalf of which an API request is sent.
+type Operation struct {
+	// Body is the value marshalled into the body of the request, or nil if the request has no body.
+	Body interface{}
+	// Name is the name of the client method, for example "AddHTTPServer".
+	// When a method calls other methods, all the requests report the method called by the user.
+	Name string
+	// Upstream is the name of the upstream the method operates on, if any.
+	Upstream string
+	// Zone is the name of the zone the method operates on, if any.
+	Zone string
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the path of the request relative to the versioned API endpoint, for example "http/upstreams".
+	Path string
+}
+
+// RequestHandler sends an API request on behalf of the operation and returns the response.
+type RequestHandler func(op Operation, req *http.Request) (*http.Response, error)
+
+// Middleware intercepts API requests. A middleware can modify the request before passing it to next
+// and inspect the response or the error returned by next.
+type Middleware func(next RequestHandler) RequestHandler
+
+// WithMiddleware adds middlewares that intercept every API request sent by the client.
+// Middlewares are called in the order they are added, so the first one sees the request first.
+// Requests retried by the retry policy go through the middlewares once.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *NginxClient) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+func chainMiddlewares(handler RequestHandler, middlewares []Middleware) RequestHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+type operationKey struct{}
+
+// withOperation records the operation in the context, unless an operation is already recorded.
+func withOperation(ctx context.Context, op Operation) context.Context {
+	if _, ok := ctx.Value(operationKey{}).(Operation); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+func operationFromContext(ctx context.Context) Operation {
+	op, _ := ctx.Value(operationKey{}).(Operation)
+	return op
+}
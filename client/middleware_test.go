@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-ID") != "test-id" {
+			t.Errorf("expected the X-Request-ID header to be set by the middleware")
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`[]`))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var calls []string
+	var ops []Operation
+	var statuses []int
+
+	recorder := func(next RequestHandler) RequestHandler {
+		return func(op Operation, req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			calls = append(calls, "recorder")
+			ops = append(ops, op)
+			mu.Unlock()
+
+			resp, err := next(op, req)
+			if err == nil {
+				mu.Lock()
+				statuses = append(statuses, resp.StatusCode)
+				mu.Unlock()
+			}
+			return resp, err
+		}
+	}
+	requestID := func(next RequestHandler) RequestHandler {
+		return func(op Operation, req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			calls = append(calls, "requestID")
+			mu.Unlock()
+
+			req.Header.Set("X-Request-ID", "test-id")
+			return next(op, req)
+		}
+	}
+
+	c, err := NewNginxClient(ts.URL, WithMiddleware(recorder), WithMiddleware(requestID))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := UpstreamServer{Server: "127.0.0.1:80"}
+	err = c.AddHTTPServer(context.Background(), "test", server)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedCalls := []string{"recorder", "requestID", "recorder", "requestID"}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Fatalf("expected middleware calls %v, got %v", expectedCalls, calls)
+	}
+
+	expectedOps := []Operation{
+		{
+			Name:     "AddHTTPServer",
+			Upstream: "test",
+			Method:   http.MethodGet,
+			Path:     "http/upstreams/test/servers",
+		},
+		{
+			Name:     "AddHTTPServer",
+			Upstream: "test",
+			Method:   http.MethodPost,
+			Path:     "http/upstreams/test/servers/",
+			Body:     &server,
+		},
+	}
+	if !reflect.DeepEqual(ops, expectedOps) {
+		t.Fatalf("expected operations %+v, got %+v", expectedOps, ops)
+	}
+
+	expectedStatuses := []int{http.StatusOK, http.StatusCreated}
+	if !reflect.DeepEqual(statuses, expectedStatuses) {
+		t.Fatalf("expected statuses %v, got %v", expectedStatuses, statuses)
+	}
+}
+
+func TestWithMiddlewareZoneOperation(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"key":"val"}`))
+	}))
+	defer ts.Close()
+
+	var op Operation
+	c, err := NewNginxClient(ts.URL, WithMiddleware(func(next RequestHandler) RequestHandler {
+		return func(o Operation, req *http.Request) (*http.Response, error) {
+			op = o
+			return next(o, req)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = c.GetStreamKeyValPairs(context.Background(), "zone")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Operation{Name: "GetStreamKeyValPairs", Zone: "zone", Method: http.MethodGet, Path: "stream/keyvals/zone"}
+	if !reflect.DeepEqual(op, expected) {
+		t.Fatalf("expected operation %+v, got %+v", expected, op)
+	}
+}
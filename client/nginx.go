@@ -3,11 +3,15 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"slices"
@@ -27,6 +31,10 @@ const (
 	streamContext     = true
 	httpContext       = false
 	defaultServerPort = "80"
+
+	unixSocketPrefix = "unix:"
+	// unixSocketHost is the placeholder host used in request URLs when the API is accessed over a unix socket.
+	unixSocketHost = "http://localhost"
 )
 
 var (
@@ -50,14 +58,55 @@ var (
 	ErrInvalidTimeout      = errors.New("invalid timeout")
 	ErrParameterMismatch   = errors.New("encountered duplicate server with different parameters")
 	ErrPlusVersionNotFound = errors.New("plus version not found in the input string")
+	ErrInvalidUnixSocket   = errors.New("invalid unix socket")
+	ErrInvalidRetryPolicy  = errors.New("invalid retry policy")
+	ErrNotFound            = errors.New("not found")
+	ErrReadOnly            = errors.New("API is read-only")
+)
+
+// Errors matching the error codes returned by the NGINX Plus API. An APIError matches, using errors.Is,
+// the error of its code. ErrServerNotFound matches the UpstreamServerNotFound code,
+// and ErrNotFound matches any APIError with the 404 status code.
+var (
+	ErrUpstreamNotFound  = errors.New("upstream not found")
+	ErrKeyvalNotFound    = errors.New("keyval zone not found")
+	ErrKeyvalKeyNotFound = errors.New("key not found")
+	ErrKeyvalKeyExists   = errors.New("key already exists")
+	ErrMethodDisabled    = errors.New("method disabled")
+	ErrUpstreamStatic    = errors.New("upstream not modifiable")
+	ErrPathNotFound      = errors.New("path not found")
+
+	apiErrorCodes = map[string]error{
+		"UpstreamNotFound":       ErrUpstreamNotFound,
+		"UpstreamServerNotFound": ErrServerNotFound,
+		"KeyvalNotFound":         ErrKeyvalNotFound,
+		"KeyvalKeyNotFound":      ErrKeyvalKeyNotFound,
+		"KeyvalKeyExists":        ErrKeyvalKeyExists,
+		"MethodDisabled":         ErrMethodDisabled,
+		"UpstreamStatic":         ErrUpstreamStatic,
+		pathNotFoundCode:         ErrPathNotFound,
+	}
 )
 
 // NginxClient lets you access NGINX Plus API.
 type NginxClient struct {
-	httpClient  *http.Client
-	apiEndpoint string
-	apiVersion  int
-	checkAPI    bool
+	httpClient        *http.Client
+	apiEndpoint       string
+	socketPath        string
+	retryPolicy       *RetryPolicy
+	drain             *DrainOptions
+	guard             *SafetyGuard
+	handler           RequestHandler
+	logger            *slog.Logger
+	tlsConfig         *tls.Config
+	auth              *authentication
+	middlewares       []Middleware
+	capabilities      capabilitiesCache
+	apiVersion        int
+	updateConcurrency int
+	conflictRetries   int
+	checkAPI          bool
+	maxAPIVersion     bool
 }
 
 type Option func(*NginxClient)
@@ -65,33 +114,43 @@ type Option func(*NginxClient)
 type versions []int
 
 // UpstreamServer lets you configure HTTP upstreams.
+// A server with a hostname and Resolve, or with a Service, is resolved by NGINX into servers
+// whose Parent is the ID of the server and whose Host is its hostname.
 type UpstreamServer struct {
 	MaxConns    *int   `json:"max_conns,omitempty"`
 	MaxFails    *int   `json:"max_fails,omitempty"`
 	Backup      *bool  `json:"backup,omitempty"`
 	Down        *bool  `json:"down,omitempty"`
 	Weight      *int   `json:"weight,omitempty"`
+	Parent      *int   `json:"parent,omitempty"`
 	Server      string `json:"server"`
 	FailTimeout string `json:"fail_timeout,omitempty"`
 	SlowStart   string `json:"slow_start,omitempty"`
 	Route       string `json:"route,omitempty"`
 	Service     string `json:"service,omitempty"`
+	Host        string `json:"host,omitempty"`
 	ID          int    `json:"id,omitempty"`
 	Drain       bool   `json:"drain,omitempty"`
+	Resolve     bool   `json:"resolve,omitempty"`
 }
 
 // StreamUpstreamServer lets you configure Stream upstreams.
+// A server with a hostname and Resolve, or with a Service, is resolved by NGINX into servers
+// whose Parent is the ID of the server and whose Host is its hostname.
 type StreamUpstreamServer struct {
 	MaxConns    *int   `json:"max_conns,omitempty"`
 	MaxFails    *int   `json:"max_fails,omitempty"`
 	Backup      *bool  `json:"backup,omitempty"`
 	Down        *bool  `json:"down,omitempty"`
 	Weight      *int   `json:"weight,omitempty"`
+	Parent      *int   `json:"parent,omitempty"`
 	Server      string `json:"server"`
 	FailTimeout string `json:"fail_timeout,omitempty"`
 	SlowStart   string `json:"slow_start,omitempty"`
 	Service     string `json:"service,omitempty"`
+	Host        string `json:"host,omitempty"`
 	ID          int    `json:"id,omitempty"`
+	Resolve     bool   `json:"resolve,omitempty"`
 }
 
 type apiErrorResponse struct {
@@ -111,54 +170,100 @@ type apiError struct {
 	Status int    `json:"status"`
 }
 
-type internalError struct {
-	err string
-	apiError
-}
+// APIError is returned when the NGINX Plus API responds with an unexpected status code.
+// The Code, Text, RequestID and Href fields come from the error object returned by the API,
+// and are empty if the response body does not contain one.
+// Use errors.As to inspect an APIError returned by the client.
+type APIError struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the path of the request relative to the versioned API endpoint, for example "http/upstreams".
+	Path string
+	// Code is the error code returned by the API, for example "UpstreamNotFound".
+	Code string
+	// Text is the description of the error returned by the API.
+	Text string
+	// RequestID is the ID of the request returned by the API.
+	RequestID string
+	// Href is the link to the documentation returned by the API.
+	Href    string
+	message string
+	details string
+	// Body is the raw body of the response.
+	Body []byte
+	// ExpectedStatus is the status code the client expected.
+	ExpectedStatus int
+	// Status is the status code of the response.
+	Status int
+}
+
+// Error allows APIError to match the Error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v. %v", e.message, e.details)
+}
+
+// Is reports whether the error code of the APIError corresponds to the target error.
+func (e *APIError) Is(target error) bool {
+	if target == ErrNotFound {
+		return e.Status == http.StatusNotFound
+	}
+	codeErr, ok := apiErrorCodes[e.Code]
+	return ok && codeErr == target
+}
+
+// newAPIError creates an APIError from the unexpected response to the request for the API path.
+// The message describes what the client was doing.
+func newAPIError(message string, req *http.Request, path string, expectedStatus int, resp *http.Response) *APIError {
+	apiErr := &APIError{
+		Method:         req.Method,
+		Path:           path,
+		message:        message,
+		ExpectedStatus: expectedStatus,
+		Status:         resp.StatusCode,
+	}
 
-// Error allows internalError to match the Error interface.
-func (internalError *internalError) Error() string {
-	return internalError.err
-}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		apiErr.details = fmt.Sprintf("failed to read the response body: %v", err)
+		return apiErr
+	}
+	apiErr.Body = body
 
-// Wrap is a way of including current context while preserving previous error information,
-// similar to `return fmt.Errorf("error doing foo, err: %v", err)` but for our internalError type.
-func (internalError *internalError) Wrap(err string) *internalError {
-	internalError.err = fmt.Sprintf("%v. %v", err, internalError.err)
-	return internalError
-}
+	var apiErrResp apiErrorResponse
+	err = json.Unmarshal(body, &apiErrResp)
+	if err != nil {
+		apiErr.details = fmt.Sprintf("failed to read the response body: error unmarshalling apiErrorResponse: got %q response: %v", string(body), err)
+		return apiErr
+	}
 
-// this is an internal representation of the Stats object including endpoint and streamEndpoint lists.
-type extendedStats struct {
-	endpoints       []string
-	streamEndpoints []string
-	Stats
+	apiErr.Code = apiErrResp.Error.Code
+	apiErr.Text = apiErrResp.Error.Text
+	apiErr.RequestID = apiErrResp.RequestID
+	apiErr.Href = apiErrResp.Href
+	apiErr.details = apiErrResp.toString()
+	return apiErr
 }
 
-func defaultStats() *extendedStats {
-	return &extendedStats{
-		endpoints:       []string{},
-		streamEndpoints: []string{},
-		Stats: Stats{
-			Upstreams:              map[string]Upstream{},
-			ServerZones:            map[string]ServerZone{},
-			StreamServerZones:      map[string]StreamServerZone{},
-			StreamUpstreams:        map[string]StreamUpstream{},
-			Slabs:                  map[string]Slab{},
-			Caches:                 map[string]HTTPCache{},
-			HTTPLimitConnections:   map[string]LimitConnection{},
-			StreamLimitConnections: map[string]LimitConnection{},
-			HTTPLimitRequests:      map[string]HTTPLimitRequest{},
-			Resolvers:              map[string]Resolver{},
-			LocationZones:          map[string]LocationZone{},
-			StreamZoneSync:         nil,
-			Workers:                []*Workers{},
-			NginxInfo:              NginxInfo{},
-			SSL:                    SSL{},
-			Connections:            Connections{},
-			HTTPRequests:           HTTPRequests{},
-			Processes:              Processes{},
-		},
+func defaultStats() *Stats {
+	return &Stats{
+		Upstreams:              map[string]Upstream{},
+		ServerZones:            map[string]ServerZone{},
+		StreamServerZones:      map[string]StreamServerZone{},
+		StreamUpstreams:        map[string]StreamUpstream{},
+		Slabs:                  map[string]Slab{},
+		Caches:                 map[string]HTTPCache{},
+		HTTPLimitConnections:   map[string]LimitConnection{},
+		StreamLimitConnections: map[string]LimitConnection{},
+		HTTPLimitRequests:      map[string]HTTPLimitRequest{},
+		Resolvers:              map[string]Resolver{},
+		LocationZones:          map[string]LocationZone{},
+		StreamZoneSync:         nil,
+		Workers:                []*Workers{},
+		NginxInfo:              NginxInfo{},
+		SSL:                    SSL{},
+		Connections:            Connections{},
+		HTTPRequests:           HTTPRequests{},
+		Processes:              Processes{},
 	}
 }
 
@@ -185,6 +290,29 @@ type Stats struct {
 	Processes              Processes
 }
 
+// StatsOption configures GetStatsWithFields.
+type StatsOption func(*statsOptions)
+
+type statsOptions struct {
+	fields map[string][]string
+}
+
+// statsSections are the API paths of the sections of Stats.
+var statsSections = []string{
+	"nginx", "processes", "connections", "slabs", "ssl", "resolvers", "workers",
+	"http/requests", "http/server_zones", "http/location_zones", "http/caches", "http/upstreams",
+	"http/limit_reqs", "http/limit_conns",
+	"stream/server_zones", "stream/upstreams", "stream/limit_conns", "stream/zone_sync",
+}
+
+// WithStatsFields limits the fields fetched by GetStatsWithFields for a section of the stats.
+// The section is the API path of the section, for example "http/upstreams" or "stream/server_zones".
+func WithStatsFields(section string, fields ...string) StatsOption {
+	return func(o *statsOptions) {
+		o.fields[section] = fields
+	}
+}
+
 // NginxInfo contains general information about NGINX Plus.
 type NginxInfo struct {
 	Version         string
@@ -607,19 +735,24 @@ func WithCheckAPI() Option {
 }
 
 // WithMaxAPIVersion sets the API version to the max API version.
+// The version is negotiated with the server once all the other options have been applied.
 func WithMaxAPIVersion() Option {
 	return func(o *NginxClient) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		version, err := o.GetMaxAPIVersion(ctx)
-		if err != nil {
-			return
-		}
-		o.apiVersion = version
+		o.maxAPIVersion = true
+	}
+}
+
+// WithUnixSocket sets the path of the unix domain socket used to access the API.
+// The host of the API endpoint is ignored and every request is sent over the socket.
+func WithUnixSocket(socketPath string) Option {
+	return func(o *NginxClient) {
+		o.socketPath = socketPath
 	}
 }
 
 // NewNginxClient creates a new NginxClient.
+// The API endpoint is either a URL, such as "http://127.0.0.1:8080/api", or a unix domain socket
+// with an optional URI, such as "unix:/var/run/nginx-api.sock" or "unix:/var/run/nginx-api.sock:/api".
 func NewNginxClient(apiEndpoint string, opts ...Option) (*NginxClient, error) {
 	c := &NginxClient{
 		httpClient:  http.DefaultClient,
@@ -628,6 +761,12 @@ func NewNginxClient(apiEndpoint string, opts ...Option) (*NginxClient, error) {
 		checkAPI:    false,
 	}
 
+	if strings.HasPrefix(apiEndpoint, unixSocketPrefix) {
+		socketPath, uri, _ := strings.Cut(strings.TrimPrefix(apiEndpoint, unixSocketPrefix), ":")
+		c.socketPath = socketPath
+		c.apiEndpoint = unixSocketHost + strings.TrimSuffix(uri, "/")
+	}
+
 	for _, opt := range opts {
 		opt(c)
 	}
@@ -636,6 +775,45 @@ func NewNginxClient(apiEndpoint string, opts ...Option) (*NginxClient, error) {
 		return nil, fmt.Errorf("http client: %w", ErrParameterRequired)
 	}
 
+	if c.retryPolicy != nil {
+		if err := c.retryPolicy.validate(); err != nil {
+			return nil, fmt.Errorf("retry policy: %w", err)
+		}
+	}
+
+	middlewares := c.middlewares
+	if c.logger != nil {
+		middlewares = append(slices.Clip(middlewares), c.logRequests)
+	}
+	if c.auth != nil {
+		if err := c.auth.validate(); err != nil {
+			return nil, fmt.Errorf("authentication: %w", err)
+		}
+		middlewares = append(slices.Clip(middlewares), c.auth.authenticate)
+	}
+	c.handler = chainMiddlewares(c.retry, middlewares)
+
+	if c.socketPath != "" && !strings.HasPrefix(c.socketPath, "/") {
+		return nil, fmt.Errorf("unix socket %v: path must be absolute: %w", c.socketPath, ErrInvalidUnixSocket)
+	}
+
+	if c.socketPath != "" || c.tlsConfig != nil {
+		httpClient, err := newHTTPClientWithTransport(c.httpClient, c.socketPath, c.tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("http client: %w", err)
+		}
+		c.httpClient = httpClient
+	}
+
+	if c.maxAPIVersion {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		version, err := c.GetMaxAPIVersion(ctx)
+		if err == nil {
+			c.apiVersion = version
+		}
+	}
+
 	if !versionSupported(c.apiVersion) {
 		return nil, fmt.Errorf("API version %v: %w by the client", c.apiVersion, ErrNotSupported)
 	}
@@ -643,7 +821,7 @@ func NewNginxClient(apiEndpoint string, opts ...Option) (*NginxClient, error) {
 	if c.checkAPI {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		versions, err := c.getAPIVersions(ctx, c.httpClient, apiEndpoint)
+		versions, err := c.getAPIVersions(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("error accessing the API: %w", err)
 		}
@@ -662,6 +840,98 @@ func NewNginxClient(apiEndpoint string, opts ...Option) (*NginxClient, error) {
 	return c, nil
 }
 
+// newHTTPClientWithTransport returns a copy of the HTTP client with a transport which dials the unix socket
+// for every request, if the socket path is not empty, and uses the TLS config, if it is not nil.
+func newHTTPClientWithTransport(httpClient *http.Client, socketPath string, tlsConfig *tls.Config) (*http.Client, error) {
+	var transport *http.Transport
+	switch t := httpClient.Transport.(type) {
+	case nil:
+		defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("default transport: %w", ErrNotSupported)
+		}
+		transport = defaultTransport.Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, fmt.Errorf("transport %T: %w", t, ErrNotSupported)
+	}
+
+	if socketPath != "" {
+		dialer := &net.Dialer{}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	newClient := *httpClient
+	newClient.Transport = transport
+	return &newClient, nil
+}
+
+// endpoint returns the API endpoint as configured by the user, naming the unix socket when one is used.
+func (client *NginxClient) endpoint() string {
+	if client.socketPath == "" {
+		return client.apiEndpoint
+	}
+	var uri string
+	if u, err := url.Parse(client.apiEndpoint); err == nil {
+		uri = u.Path
+	}
+	if uri == "" {
+		return unixSocketPrefix + client.socketPath
+	}
+	return unixSocketPrefix + client.socketPath + ":" + uri
+}
+
+// do sends the request for the API path through the middleware chain of the client.
+// The input is the value marshalled into the body of the request, if any.
+func (client *NginxClient) do(req *http.Request, path string, input interface{}) (*http.Response, error) {
+	op := operationFromContext(req.Context())
+	op.Method = req.Method
+	op.Path = path
+	op.Body = input
+
+	return client.handler(op, req)
+}
+
+// retry sends the request, retrying it according to the retry policy of the client.
+func (client *NginxClient) retry(_ Operation, req *http.Request) (*http.Response, error) {
+	if client.retryPolicy == nil || !client.retryPolicy.retries(req.Method) {
+		return client.send(req)
+	}
+	return client.retryPolicy.do(req, client.send)
+}
+
+// send sends the request once. For clients using a unix socket, errors name the socket instead of the placeholder URL.
+func (client *NginxClient) send(req *http.Request) (*http.Response, error) {
+	resp, err := client.httpClient.Do(req)
+	if err != nil && client.socketPath != "" {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = unixSocketPrefix + client.socketPath + ":" + req.URL.RequestURI()
+		}
+	}
+	return resp, err //nolint:wrapcheck // errors are wrapped by the callers.
+}
+
+// fieldsQuery returns the fields query parameter, which limits the fields returned by the API, or an empty string if there are no fields.
+func fieldsQuery(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	escaped := make([]string, 0, len(fields))
+	for _, field := range fields {
+		escaped = append(escaped, url.QueryEscape(field))
+	}
+	return "?fields=" + strings.Join(escaped, ",")
+}
+
 func versionSupported(n int) bool {
 	for _, version := range supportedAPIVersions {
 		if n == version {
@@ -673,7 +943,8 @@ func versionSupported(n int) bool {
 
 // GetMaxAPIVersion returns the maximum API version supported by the server and the client.
 func (client *NginxClient) GetMaxAPIVersion(ctx context.Context) (int, error) {
-	serverVersions, err := client.getAPIVersions(ctx, client.httpClient, client.apiEndpoint)
+	ctx = withOperation(ctx, Operation{Name: "GetMaxAPIVersion"})
+	serverVersions, err := client.getAPIVersions(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get max API version: %w", err)
 	}
@@ -688,21 +959,23 @@ func (client *NginxClient) GetMaxAPIVersion(ctx context.Context) (int, error) {
 	return maxServerVersion, nil
 }
 
-func (client *NginxClient) getAPIVersions(ctx context.Context, httpClient *http.Client, endpoint string) (*versions, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+func (client *NginxClient) getAPIVersions(ctx context.Context) (*versions, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetAPIVersions"})
+	endpoint := client.endpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.apiEndpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a get request: %w", err)
 	}
-	resp, err := httpClient.Do(req)
+	resp, err := client.do(req, "", nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v is not accessible: %w", endpoint, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, createResponseMismatchError(resp.Body).Wrap(fmt.Sprintf(
+		return nil, newAPIError(fmt.Sprintf(
 			"failed to get endpoint %q, expected %v response, got %v",
-			endpoint, http.StatusOK, resp.StatusCode))
+			endpoint, http.StatusOK, resp.StatusCode), req, "", http.StatusOK, resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -719,43 +992,16 @@ func (client *NginxClient) getAPIVersions(ctx context.Context, httpClient *http.
 	return &vers, nil
 }
 
-func createResponseMismatchError(respBody io.ReadCloser) *internalError {
-	apiErrResp, err := readAPIErrorResponse(respBody)
-	if err != nil {
-		return &internalError{
-			err: fmt.Sprintf("failed to read the response body: %v", err),
-		}
-	}
-
-	return &internalError{
-		err:      apiErrResp.toString(),
-		apiError: apiErrResp.Error,
-	}
-}
-
-func readAPIErrorResponse(respBody io.ReadCloser) (*apiErrorResponse, error) {
-	body, err := io.ReadAll(respBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read the response body: %w", err)
-	}
-
-	var apiErr apiErrorResponse
-	err = json.Unmarshal(body, &apiErr)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling apiErrorResponse: got %q response: %w", string(body), err)
-	}
-
-	return &apiErr, nil
-}
-
 // CheckIfUpstreamExists checks if the upstream exists in NGINX. If the upstream doesn't exist, it returns the error.
 func (client *NginxClient) CheckIfUpstreamExists(ctx context.Context, upstream string) error {
+	ctx = withOperation(ctx, Operation{Name: "CheckIfUpstreamExists", Upstream: upstream})
 	_, err := client.GetHTTPServers(ctx, upstream)
 	return err
 }
 
 // GetHTTPServers returns the servers of the upstream from NGINX.
 func (client *NginxClient) GetHTTPServers(ctx context.Context, upstream string) ([]UpstreamServer, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPServers", Upstream: upstream})
 	path := fmt.Sprintf("http/upstreams/%v/servers", upstream)
 
 	var servers []UpstreamServer
@@ -769,6 +1015,10 @@ func (client *NginxClient) GetHTTPServers(ctx context.Context, upstream string)
 
 // AddHTTPServer adds the server to the upstream.
 func (client *NginxClient) AddHTTPServer(ctx context.Context, upstream string, server UpstreamServer) error {
+	ctx = withOperation(ctx, Operation{Name: "AddHTTPServer", Upstream: upstream})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to add %v server to %v upstream: %w", server.Server, upstream, err)
+	}
 	id, err := client.getIDOfHTTPServer(ctx, upstream, server.Server)
 	if err != nil {
 		return fmt.Errorf("failed to add %v server to %v upstream: %w", server.Server, upstream, err)
@@ -776,22 +1026,38 @@ func (client *NginxClient) AddHTTPServer(ctx context.Context, upstream string, s
 	if id != -1 {
 		return fmt.Errorf("failed to add %v server to %v upstream: %w", server.Server, upstream, ErrServerExists)
 	}
-	err = client.addHTTPServer(ctx, upstream, server)
-	return err
+	id, err = client.createHTTPServer(ctx, upstream, server)
+	if err != nil || client.conflictRetries == 0 || id == -1 {
+		return err
+	}
+	return client.resolveConcurrentHTTPAdd(ctx, upstream, addPortToServer(server.Server), id)
 }
 
 func (client *NginxClient) addHTTPServer(ctx context.Context, upstream string, server UpstreamServer) error {
+	_, err := client.createHTTPServer(ctx, upstream, server)
+	return err
+}
+
+// createHTTPServer adds the server to the upstream and returns its ID, or -1 if the API didn't return it.
+func (client *NginxClient) createHTTPServer(ctx context.Context, upstream string, server UpstreamServer) (int, error) {
+	server.Parent = nil
+	server.Host = ""
 	path := fmt.Sprintf("http/upstreams/%v/servers/", upstream)
-	err := client.post(ctx, path, &server)
+	created := UpstreamServer{ID: -1}
+	err := client.post(ctx, path, &server, &created)
 	if err != nil {
-		return fmt.Errorf("failed to add %v server to %v upstream: %w", server.Server, upstream, err)
+		return -1, fmt.Errorf("failed to add %v server to %v upstream: %w", server.Server, upstream, err)
 	}
 
-	return nil
+	return created.ID, nil
 }
 
 // DeleteHTTPServer the server from the upstream.
 func (client *NginxClient) DeleteHTTPServer(ctx context.Context, upstream string, server string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteHTTPServer", Upstream: upstream})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to remove %v server from %v upstream: %w", server, upstream, err)
+	}
 	id, err := client.getIDOfHTTPServer(ctx, upstream, server)
 	if err != nil {
 		return fmt.Errorf("failed to remove %v server from  %v upstream: %w", server, upstream, err)
@@ -804,6 +1070,14 @@ func (client *NginxClient) DeleteHTTPServer(ctx context.Context, upstream string
 }
 
 func (client *NginxClient) deleteHTTPServer(ctx context.Context, upstream, server string, serverID int) error {
+	if client.drain != nil {
+		return client.drainAndDeleteHTTPServer(ctx, upstream, server, serverID, *client.drain)
+	}
+	return client.removeHTTPServer(ctx, upstream, server, serverID)
+}
+
+// removeHTTPServer removes the server from the upstream at once, without draining it.
+func (client *NginxClient) removeHTTPServer(ctx context.Context, upstream, server string, serverID int) error {
 	path := fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, serverID)
 	err := client.delete(ctx, path, http.StatusOK)
 	if err != nil {
@@ -821,54 +1095,78 @@ func (client *NginxClient) deleteHTTPServer(ctx context.Context, upstream, serve
 // If there are duplicate servers with equivalent parameters, the duplicates will be ignored.
 // If there are duplicate servers with different parameters, those server entries will be ignored and an error returned.
 func (client *NginxClient) UpdateHTTPServers(ctx context.Context, upstream string, servers []UpstreamServer) (added []UpstreamServer, deleted []UpstreamServer, updated []UpstreamServer, err error) {
-	serversInNginx, err := client.GetHTTPServers(ctx, upstream)
+	ctx = withOperation(ctx, Operation{Name: "UpdateHTTPServers", Upstream: upstream})
+	err = client.checkWritable(ctx)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, err)
 	}
-
-	// We assume port 80 if no port is set for servers.
-	formattedServers := make([]UpstreamServer, 0, len(servers))
-	for _, server := range servers {
-		server.Server = addPortToServer(server.Server)
-		formattedServers = append(formattedServers, server)
+	formattedServers, formatErr := formatServers(upstream, servers)
+	if client.conflictRetries > 0 {
+		// Servers removed concurrently are handled by the retries.
+		ctx = expectingErrors(ctx, ErrServerNotFound)
 	}
 
-	formattedServers, err = deduplicateServers(upstream, formattedServers)
-
-	toAdd, toDelete, toUpdate := determineUpdates(formattedServers, serversInNginx)
+	err = client.retryConflicts(ctx, upstream, func() error {
+		a, d, u, updateErr := client.updateHTTPServers(ctx, upstream, formattedServers)
+		added = append(added, a...)
+		deleted = append(deleted, d...)
+		updated = append(updated, u...)
+		return updateErr
+	}, func() (bool, error) {
+		return client.httpServersConverged(ctx, upstream, formattedServers)
+	})
+	err = errors.Join(formatErr, err)
 
-	for _, server := range toAdd {
-		addErr := client.addHTTPServer(ctx, upstream, server)
-		if addErr != nil {
-			err = errors.Join(err, addErr)
-			continue
-		}
-		added = append(added, server)
+	if err != nil {
+		err = fmt.Errorf("failed to update servers of %s upstream: %w", upstream, err)
 	}
 
-	for _, server := range toDelete {
-		deleteErr := client.deleteHTTPServer(ctx, upstream, server.Server, server.ID)
-		if deleteErr != nil {
-			err = errors.Join(err, deleteErr)
-			continue
-		}
-		deleted = append(deleted, server)
+	return added, deleted, updated, err
+}
+
+// updateHTTPServers makes the changes to the servers of the upstream, once.
+func (client *NginxClient) updateHTTPServers(ctx context.Context, upstream string, servers []UpstreamServer) (added []UpstreamServer, deleted []UpstreamServer, updated []UpstreamServer, err error) {
+	serversInNginx, err := client.GetHTTPServers(ctx, upstream)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	for _, server := range toUpdate {
-		updateErr := client.UpdateHTTPServer(ctx, upstream, server)
-		if updateErr != nil {
-			err = errors.Join(err, updateErr)
-			continue
+	toAdd, toDelete, toUpdate := client.determineHTTPServerUpdates(servers, serversInNginx)
+	client.logUpdates(ctx, upstream, upstreamServerAddresses(toAdd), upstreamServerAddresses(toDelete), upstreamServerAddresses(toUpdate))
+
+	if client.guard != nil {
+		plan, err := client.newHTTPServersPlan(upstream, servers, serversInNginx)
+		if err == nil {
+			err = client.checkGuard(ctx, plan, len(configuredServers(serversInNginx)))
+		}
+		if err != nil {
+			return nil, nil, nil, err
 		}
-		updated = append(updated, server)
 	}
 
-	if err != nil {
-		err = fmt.Errorf("failed to update servers of %s upstream: %w", upstream, err)
-	}
+	added, addErr := applyUpdates(client.updateConcurrency, toAdd, func(server UpstreamServer) error {
+		return client.addHTTPServer(ctx, upstream, server)
+	})
+	deleted, deleteErr := applyUpdates(client.updateConcurrency, toDelete, func(server UpstreamServer) error {
+		return client.deleteHTTPServer(ctx, upstream, server.Server, server.ID)
+	})
+	updated, updateErr := applyUpdates(client.updateConcurrency, toUpdate, func(server UpstreamServer) error {
+		return client.UpdateHTTPServer(ctx, upstream, server)
+	})
+	return added, deleted, updated, errors.Join(addErr, deleteErr, updateErr)
+}
 
-	return added, deleted, updated, err
+// formatServers adds the default port to the servers without one and removes the duplicate servers.
+func formatServers(upstream string, servers []UpstreamServer) ([]UpstreamServer, error) {
+	// We assume port 80 if no port is set for servers, except for servers with a service, resolved from SRV records.
+	formattedServers := make([]UpstreamServer, 0, len(servers))
+	for _, server := range servers {
+		if server.Service == "" {
+			server.Server = addPortToServer(server.Server)
+		}
+		formattedServers = append(formattedServers, server)
+	}
+	return deduplicateServers(upstream, formattedServers)
 }
 
 func deduplicateServers(upstream string, servers []UpstreamServer) ([]UpstreamServer, error) {
@@ -907,6 +1205,9 @@ func deduplicateServers(upstream string, servers []UpstreamServer) ([]UpstreamSe
 // hasSameParametersAs checks if a given server has the same parameters.
 func (s UpstreamServer) hasSameParametersAs(compareServer UpstreamServer) bool {
 	s.ID = compareServer.ID
+	s.Parent = compareServer.Parent
+	s.Host = compareServer.Host
+	s.Resolve = compareServer.Resolve
 	s.applyDefaults()
 	compareServer.applyDefaults()
 	return reflect.DeepEqual(s, compareServer)
@@ -943,10 +1244,11 @@ func (s *UpstreamServer) applyDefaults() {
 }
 
 func determineUpdates(updatedServers []UpstreamServer, nginxServers []UpstreamServer) (toAdd []UpstreamServer, toRemove []UpstreamServer, toUpdate []UpstreamServer) {
+	nginxServers = configuredServers(nginxServers)
 	for _, server := range updatedServers {
 		updateFound := false
 		for _, serverNGX := range nginxServers {
-			if server.Server == serverNGX.Server && !server.hasSameParametersAs(serverNGX) {
+			if sameServer(server.Server, serverNGX.Server) && !server.hasSameParametersAs(serverNGX) {
 				server.ID = serverNGX.ID
 				updateFound = true
 				break
@@ -960,7 +1262,7 @@ func determineUpdates(updatedServers []UpstreamServer, nginxServers []UpstreamSe
 	for _, server := range updatedServers {
 		found := false
 		for _, serverNGX := range nginxServers {
-			if server.Server == serverNGX.Server {
+			if sameServer(server.Server, serverNGX.Server) {
 				found = true
 				break
 			}
@@ -973,7 +1275,7 @@ func determineUpdates(updatedServers []UpstreamServer, nginxServers []UpstreamSe
 	for _, serverNGX := range nginxServers {
 		found := false
 		for _, server := range updatedServers {
-			if serverNGX.Server == server.Server {
+			if sameServer(server.Server, serverNGX.Server) {
 				found = true
 				break
 			}
@@ -1002,23 +1304,34 @@ func (client *NginxClient) getIDOfHTTPServer(ctx context.Context, upstream strin
 }
 
 func (client *NginxClient) get(ctx context.Context, path string, data interface{}) error {
-	url := fmt.Sprintf("%v/%v/%v", client.apiEndpoint, client.apiVersion, path)
+	return client.getWithFields(ctx, path, nil, data)
+}
+
+// getWithFields gets the path, limiting the response to the fields, if any.
+// The fields query parameter is only added to the URL, the operation and errors report the path alone.
+func (client *NginxClient) getWithFields(ctx context.Context, path string, fields []string, data interface{}) error {
+	if err := checkAPIVersion(path, client.apiVersion); err != nil {
+		return err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	apiURL := fmt.Sprintf("%v/%v/%v%v", client.apiEndpoint, client.apiVersion, path, fieldsQuery(fields))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create a get request: %w", err)
 	}
 
-	resp, err := client.httpClient.Do(req)
+	resp, err := client.do(req, path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get %v: %w", path, err)
 	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		return createResponseMismatchError(resp.Body).Wrap(fmt.Sprintf(
+		return newAPIError(fmt.Sprintf(
 			"expected %v response, got %v",
-			http.StatusOK, resp.StatusCode))
+			http.StatusOK, resp.StatusCode), req, path, http.StatusOK, resp)
 	}
-	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -1032,93 +1345,128 @@ func (client *NginxClient) get(ctx context.Context, path string, data interface{
 	return nil
 }
 
-func (client *NginxClient) post(ctx context.Context, path string, input interface{}) error {
-	url := fmt.Sprintf("%v/%v/%v", client.apiEndpoint, client.apiVersion, path)
+// post creates the input at the path. The output, if not nil, is set to the object created, if the API returns it.
+func (client *NginxClient) post(ctx context.Context, path string, input interface{}, output interface{}) error {
+	if err := checkAPIVersion(path, client.apiVersion); err != nil {
+		return err
+	}
+
+	if err := client.checkWritable(ctx); err != nil {
+		return err
+	}
+
+	apiURL := fmt.Sprintf("%v/%v/%v", client.apiEndpoint, client.apiVersion, path)
 
 	jsonInput, err := json.Marshal(input)
 	if err != nil {
 		return fmt.Errorf("failed to marshall input: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonInput))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(jsonInput))
 	if err != nil {
 		return fmt.Errorf("failed to create a post request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.httpClient.Do(req)
+	resp, err := client.do(req, path, input)
 	if err != nil {
 		return fmt.Errorf("failed to post %v: %w", path, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return createResponseMismatchError(resp.Body).Wrap(fmt.Sprintf(
+		return client.writeError(newAPIError(fmt.Sprintf(
 			"expected %v response, got %v",
-			http.StatusCreated, resp.StatusCode))
+			http.StatusCreated, resp.StatusCode), req, path, http.StatusCreated, resp))
+	}
+	if output == nil {
+		return nil
 	}
 
+	// The object was created, so a response which can't be read leaves the output unchanged instead of failing.
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		_ = json.Unmarshal(body, output)
+	}
 	return nil
 }
 
 func (client *NginxClient) delete(ctx context.Context, path string, expectedStatusCode int) error {
-	path = fmt.Sprintf("%v/%v/%v/", client.apiEndpoint, client.apiVersion, path)
+	if err := checkAPIVersion(path, client.apiVersion); err != nil {
+		return err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, path, nil)
+	if err := client.checkWritable(ctx); err != nil {
+		return err
+	}
+
+	apiURL := fmt.Sprintf("%v/%v/%v/", client.apiEndpoint, client.apiVersion, path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, apiURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create a delete request: %w", err)
 	}
 
-	resp, err := client.httpClient.Do(req)
+	resp, err := client.do(req, path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create delete request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != expectedStatusCode {
-		return createResponseMismatchError(resp.Body).Wrap(fmt.Sprintf(
+		return client.writeError(newAPIError(fmt.Sprintf(
 			"failed to complete delete request: expected %v response, got %v",
-			expectedStatusCode, resp.StatusCode))
+			expectedStatusCode, resp.StatusCode), req, path, expectedStatusCode, resp))
 	}
 	return nil
 }
 
 func (client *NginxClient) patch(ctx context.Context, path string, input interface{}, expectedStatusCode int) error {
-	path = fmt.Sprintf("%v/%v/%v/", client.apiEndpoint, client.apiVersion, path)
+	if err := checkAPIVersion(path, client.apiVersion); err != nil {
+		return err
+	}
+
+	if err := client.checkWritable(ctx); err != nil {
+		return err
+	}
+
+	apiURL := fmt.Sprintf("%v/%v/%v/", client.apiEndpoint, client.apiVersion, path)
 
 	jsonInput, err := json.Marshal(input)
 	if err != nil {
 		return fmt.Errorf("failed to marshall input: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, path, bytes.NewBuffer(jsonInput))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, apiURL, bytes.NewBuffer(jsonInput))
 	if err != nil {
 		return fmt.Errorf("failed to create a patch request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.httpClient.Do(req)
+	resp, err := client.do(req, path, input)
 	if err != nil {
 		return fmt.Errorf("failed to create patch request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != expectedStatusCode {
-		return createResponseMismatchError(resp.Body).Wrap(fmt.Sprintf(
+		return client.writeError(newAPIError(fmt.Sprintf(
 			"failed to complete patch request: expected %v response, got %v",
-			expectedStatusCode, resp.StatusCode))
+			expectedStatusCode, resp.StatusCode), req, path, expectedStatusCode, resp))
 	}
 	return nil
 }
 
 // CheckIfStreamUpstreamExists checks if the stream upstream exists in NGINX. If the upstream doesn't exist, it returns the error.
 func (client *NginxClient) CheckIfStreamUpstreamExists(ctx context.Context, upstream string) error {
+	ctx = withOperation(ctx, Operation{Name: "CheckIfStreamUpstreamExists", Upstream: upstream})
 	_, err := client.GetStreamServers(ctx, upstream)
 	return err
 }
 
 // GetStreamServers returns the stream servers of the upstream from NGINX.
 func (client *NginxClient) GetStreamServers(ctx context.Context, upstream string) ([]StreamUpstreamServer, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServers", Upstream: upstream})
 	path := fmt.Sprintf("stream/upstreams/%v/servers", upstream)
 
 	var servers []StreamUpstreamServer
@@ -1131,6 +1479,10 @@ func (client *NginxClient) GetStreamServers(ctx context.Context, upstream string
 
 // AddStreamServer adds the stream server to the upstream.
 func (client *NginxClient) AddStreamServer(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+	ctx = withOperation(ctx, Operation{Name: "AddStreamServer", Upstream: upstream})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to add %v stream server to %v upstream: %w", server.Server, upstream, err)
+	}
 	id, err := client.getIDOfStreamServer(ctx, upstream, server.Server)
 	if err != nil {
 		return fmt.Errorf("failed to add %v stream server to %v upstream: %w", server.Server, upstream, err)
@@ -1138,21 +1490,37 @@ func (client *NginxClient) AddStreamServer(ctx context.Context, upstream string,
 	if id != -1 {
 		return fmt.Errorf("failed to add %v stream server to %v upstream: %w", server.Server, upstream, ErrServerExists)
 	}
-	err = client.addStreamServer(ctx, upstream, server)
-	return err
+	id, err = client.createStreamServer(ctx, upstream, server)
+	if err != nil || client.conflictRetries == 0 || id == -1 {
+		return err
+	}
+	return client.resolveConcurrentStreamAdd(ctx, upstream, addPortToServer(server.Server), id)
 }
 
 func (client *NginxClient) addStreamServer(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+	_, err := client.createStreamServer(ctx, upstream, server)
+	return err
+}
+
+// createStreamServer adds the server to the stream upstream and returns its ID, or -1 if the API didn't return it.
+func (client *NginxClient) createStreamServer(ctx context.Context, upstream string, server StreamUpstreamServer) (int, error) {
+	server.Parent = nil
+	server.Host = ""
 	path := fmt.Sprintf("stream/upstreams/%v/servers/", upstream)
-	err := client.post(ctx, path, &server)
+	created := StreamUpstreamServer{ID: -1}
+	err := client.post(ctx, path, &server, &created)
 	if err != nil {
-		return fmt.Errorf("failed to add %v stream server to %v upstream: %w", server.Server, upstream, err)
+		return -1, fmt.Errorf("failed to add %v stream server to %v upstream: %w", server.Server, upstream, err)
 	}
-	return nil
+	return created.ID, nil
 }
 
 // DeleteStreamServer the server from the upstream.
 func (client *NginxClient) DeleteStreamServer(ctx context.Context, upstream string, server string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteStreamServer", Upstream: upstream})
+	if err := client.checkWritable(ctx); err != nil {
+		return fmt.Errorf("failed to remove %v stream server from %v upstream: %w", server, upstream, err)
+	}
 	id, err := client.getIDOfStreamServer(ctx, upstream, server)
 	if err != nil {
 		return fmt.Errorf("failed to remove %v stream server from  %v upstream: %w", server, upstream, err)
@@ -1181,53 +1549,65 @@ func (client *NginxClient) deleteStreamServer(ctx context.Context, upstream, ser
 // If there are duplicate servers with equivalent parameters, the duplicates will be ignored.
 // If there are duplicate servers with different parameters, those server entries will be ignored and an error returned.
 func (client *NginxClient) UpdateStreamServers(ctx context.Context, upstream string, servers []StreamUpstreamServer) (added []StreamUpstreamServer, deleted []StreamUpstreamServer, updated []StreamUpstreamServer, err error) {
-	serversInNginx, err := client.GetStreamServers(ctx, upstream)
+	ctx = withOperation(ctx, Operation{Name: "UpdateStreamServers", Upstream: upstream})
+	err = client.checkWritable(ctx)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, err)
 	}
-
-	formattedServers := make([]StreamUpstreamServer, 0, len(servers))
-	for _, server := range servers {
-		server.Server = addPortToServer(server.Server)
-		formattedServers = append(formattedServers, server)
+	formattedServers, formatErr := formatStreamServers(upstream, servers)
+	if client.conflictRetries > 0 {
+		// Servers removed concurrently are handled by the retries.
+		ctx = expectingErrors(ctx, ErrServerNotFound)
 	}
 
-	formattedServers, err = deduplicateStreamServers(upstream, formattedServers)
-
-	toAdd, toDelete, toUpdate := determineStreamUpdates(formattedServers, serversInNginx)
+	err = client.retryConflicts(ctx, upstream, func() error {
+		a, d, u, updateErr := client.updateStreamServers(ctx, upstream, formattedServers)
+		added = append(added, a...)
+		deleted = append(deleted, d...)
+		updated = append(updated, u...)
+		return updateErr
+	}, func() (bool, error) {
+		return client.streamServersConverged(ctx, upstream, formattedServers)
+	})
+	err = errors.Join(formatErr, err)
 
-	for _, server := range toAdd {
-		addErr := client.addStreamServer(ctx, upstream, server)
-		if addErr != nil {
-			err = errors.Join(err, addErr)
-			continue
-		}
-		added = append(added, server)
+	if err != nil {
+		err = fmt.Errorf("failed to update stream servers of %s upstream: %w", upstream, err)
 	}
 
-	for _, server := range toDelete {
-		deleteErr := client.deleteStreamServer(ctx, upstream, server.Server, server.ID)
-		if deleteErr != nil {
-			err = errors.Join(err, deleteErr)
-			continue
-		}
-		deleted = append(deleted, server)
-	}
+	return added, deleted, updated, err
+}
 
-	for _, server := range toUpdate {
-		updateErr := client.UpdateStreamServer(ctx, upstream, server)
-		if updateErr != nil {
-			err = errors.Join(err, updateErr)
-			continue
-		}
-		updated = append(updated, server)
+// updateStreamServers makes the changes to the servers of the upstream, once.
+func (client *NginxClient) updateStreamServers(ctx context.Context, upstream string, servers []StreamUpstreamServer) (added []StreamUpstreamServer, deleted []StreamUpstreamServer, updated []StreamUpstreamServer, err error) {
+	serversInNginx, err := client.GetStreamServers(ctx, upstream)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	if err != nil {
-		err = fmt.Errorf("failed to update stream servers of %s upstream: %w", upstream, err)
+	toAdd, toDelete, toUpdate := client.determineStreamServerUpdates(servers, serversInNginx)
+	client.logUpdates(ctx, upstream, streamUpstreamServerAddresses(toAdd), streamUpstreamServerAddresses(toDelete), streamUpstreamServerAddresses(toUpdate))
+
+	if client.guard != nil {
+		plan, err := client.newStreamServersPlan(upstream, servers, serversInNginx)
+		if err == nil {
+			err = client.checkGuard(ctx, plan, len(configuredStreamServers(serversInNginx)))
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	return added, deleted, updated, err
+	added, addErr := applyUpdates(client.updateConcurrency, toAdd, func(server StreamUpstreamServer) error {
+		return client.addStreamServer(ctx, upstream, server)
+	})
+	deleted, deleteErr := applyUpdates(client.updateConcurrency, toDelete, func(server StreamUpstreamServer) error {
+		return client.deleteStreamServer(ctx, upstream, server.Server, server.ID)
+	})
+	updated, updateErr := applyUpdates(client.updateConcurrency, toUpdate, func(server StreamUpstreamServer) error {
+		return client.UpdateStreamServer(ctx, upstream, server)
+	})
+	return added, deleted, updated, errors.Join(addErr, deleteErr, updateErr)
 }
 
 func (client *NginxClient) getIDOfStreamServer(ctx context.Context, upstream string, name string) (int, error) {
@@ -1245,6 +1625,18 @@ func (client *NginxClient) getIDOfStreamServer(ctx context.Context, upstream str
 	return -1, nil
 }
 
+// formatStreamServers adds the default port to the servers without one and removes the duplicate servers.
+func formatStreamServers(upstream string, servers []StreamUpstreamServer) ([]StreamUpstreamServer, error) {
+	formattedServers := make([]StreamUpstreamServer, 0, len(servers))
+	for _, server := range servers {
+		if server.Service == "" {
+			server.Server = addPortToServer(server.Server)
+		}
+		formattedServers = append(formattedServers, server)
+	}
+	return deduplicateStreamServers(upstream, formattedServers)
+}
+
 func deduplicateStreamServers(upstream string, servers []StreamUpstreamServer) ([]StreamUpstreamServer, error) {
 	type serverCheck struct {
 		server StreamUpstreamServer
@@ -1281,6 +1673,9 @@ func deduplicateStreamServers(upstream string, servers []StreamUpstreamServer) (
 // hasSameParametersAs checks if a given server has the same parameters.
 func (s StreamUpstreamServer) hasSameParametersAs(compareServer StreamUpstreamServer) bool {
 	s.ID = compareServer.ID
+	s.Parent = compareServer.Parent
+	s.Host = compareServer.Host
+	s.Resolve = compareServer.Resolve
 	s.applyDefaults()
 	compareServer.applyDefaults()
 	return reflect.DeepEqual(s, compareServer)
@@ -1317,10 +1712,11 @@ func (s *StreamUpstreamServer) applyDefaults() {
 }
 
 func determineStreamUpdates(updatedServers []StreamUpstreamServer, nginxServers []StreamUpstreamServer) (toAdd []StreamUpstreamServer, toRemove []StreamUpstreamServer, toUpdate []StreamUpstreamServer) {
+	nginxServers = configuredStreamServers(nginxServers)
 	for _, server := range updatedServers {
 		updateFound := false
 		for _, serverNGX := range nginxServers {
-			if server.Server == serverNGX.Server && !server.hasSameParametersAs(serverNGX) {
+			if sameServer(server.Server, serverNGX.Server) && !server.hasSameParametersAs(serverNGX) {
 				server.ID = serverNGX.ID
 				updateFound = true
 				break
@@ -1334,7 +1730,7 @@ func determineStreamUpdates(updatedServers []StreamUpstreamServer, nginxServers
 	for _, server := range updatedServers {
 		found := false
 		for _, serverNGX := range nginxServers {
-			if server.Server == serverNGX.Server {
+			if sameServer(server.Server, serverNGX.Server) {
 				found = true
 				break
 			}
@@ -1347,7 +1743,7 @@ func determineStreamUpdates(updatedServers []StreamUpstreamServer, nginxServers
 	for _, serverNGX := range nginxServers {
 		found := false
 		for _, server := range updatedServers {
-			if serverNGX.Server == server.Server {
+			if sameServer(server.Server, serverNGX.Server) {
 				found = true
 				break
 			}
@@ -1362,24 +1758,45 @@ func determineStreamUpdates(updatedServers []StreamUpstreamServer, nginxServers
 
 // GetStats gets process, slab, connection, request, ssl, zone, stream zone, upstream and stream upstream related stats from the NGINX Plus API.
 func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStats"})
+	return client.GetStatsWithFields(ctx)
+}
+
+// GetStatsWithFields gets the stats like GetStats. The options can limit the fields fetched for each section of the stats.
+func (client *NginxClient) GetStatsWithFields(ctx context.Context, opts ...StatsOption) (*Stats, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStatsWithFields"})
+	options := statsOptions{fields: map[string][]string{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	for section := range options.fields {
+		if !slices.Contains(statsSections, section) {
+			return nil, fmt.Errorf("stats section %q: %w", section, ErrNotSupported)
+		}
+	}
+
+	endpoints, streamEndpoints, err := client.statsEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// fetch collects the section of the stats, unless it is not available in the API version
+	// or, for the stream sections, in the stream endpoints.
+	fetch := func(group *errgroup.Group, section string, f func() error) {
+		if checkAPIVersion(section, client.apiVersion) != nil {
+			return
+		}
+		if stream, ok := strings.CutPrefix(section, "stream/"); ok && !slices.Contains(streamEndpoints, stream) {
+			return
+		}
+		group.Go(f)
+	}
+
 	initialGroup, initialCtx := errgroup.WithContext(ctx)
 	var mu sync.Mutex
 	stats := defaultStats()
 	// Collecting initial stats
-	initialGroup.Go(func() error {
-		endpoints, err := client.GetAvailableEndpoints(initialCtx)
-		if err != nil {
-			return fmt.Errorf("failed to get available Endpoints: %w", err)
-		}
-
-		mu.Lock()
-		stats.endpoints = endpoints
-		mu.Unlock()
-		return nil
-	})
-
-	initialGroup.Go(func() error {
-		nginxInfo, err := client.GetNginxInfo(initialCtx)
+	fetch(initialGroup, "nginx", func() error {
+		nginxInfo, err := client.GetNginxInfoWithFields(initialCtx, options.fields["nginx"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get NGINX info: %w", err)
 		}
@@ -1391,8 +1808,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		caches, err := client.GetCaches(initialCtx)
+	fetch(initialGroup, "http/caches", func() error {
+		caches, err := client.GetCachesWithFields(initialCtx, options.fields["http/caches"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Caches: %w", err)
 		}
@@ -1404,8 +1821,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		processes, err := client.GetProcesses(initialCtx)
+	fetch(initialGroup, "processes", func() error {
+		processes, err := client.GetProcessesWithFields(initialCtx, options.fields["processes"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Process information: %w", err)
 		}
@@ -1417,8 +1834,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		slabs, err := client.GetSlabs(initialCtx)
+	fetch(initialGroup, "slabs", func() error {
+		slabs, err := client.GetSlabsWithFields(initialCtx, options.fields["slabs"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Slabs: %w", err)
 		}
@@ -1430,8 +1847,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		httpRequests, err := client.GetHTTPRequests(initialCtx)
+	fetch(initialGroup, "http/requests", func() error {
+		httpRequests, err := client.GetHTTPRequestsWithFields(initialCtx, options.fields["http/requests"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get HTTP Requests: %w", err)
 		}
@@ -1443,8 +1860,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		ssl, err := client.GetSSL(initialCtx)
+	fetch(initialGroup, "ssl", func() error {
+		ssl, err := client.GetSSLWithFields(initialCtx, options.fields["ssl"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get SSL: %w", err)
 		}
@@ -1456,8 +1873,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		serverZones, err := client.GetServerZones(initialCtx)
+	fetch(initialGroup, "http/server_zones", func() error {
+		serverZones, err := client.GetServerZonesWithFields(initialCtx, options.fields["http/server_zones"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Server Zones: %w", err)
 		}
@@ -1469,8 +1886,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		upstreams, err := client.GetUpstreams(initialCtx)
+	fetch(initialGroup, "http/upstreams", func() error {
+		upstreams, err := client.GetUpstreamsWithFields(initialCtx, options.fields["http/upstreams"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Upstreams: %w", err)
 		}
@@ -1482,8 +1899,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		locationZones, err := client.GetLocationZones(initialCtx)
+	fetch(initialGroup, "http/location_zones", func() error {
+		locationZones, err := client.GetLocationZonesWithFields(initialCtx, options.fields["http/location_zones"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Location Zones: %w", err)
 		}
@@ -1495,8 +1912,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		resolvers, err := client.GetResolvers(initialCtx)
+	fetch(initialGroup, "resolvers", func() error {
+		resolvers, err := client.GetResolversWithFields(initialCtx, options.fields["resolvers"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Resolvers: %w", err)
 		}
@@ -1508,8 +1925,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		httpLimitRequests, err := client.GetHTTPLimitReqs(initialCtx)
+	fetch(initialGroup, "http/limit_reqs", func() error {
+		httpLimitRequests, err := client.GetHTTPLimitReqsWithFields(initialCtx, options.fields["http/limit_reqs"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get HTTPLimitRequests: %w", err)
 		}
@@ -1521,8 +1938,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		httpLimitConnections, err := client.GetHTTPConnectionsLimit(initialCtx)
+	fetch(initialGroup, "http/limit_conns", func() error {
+		httpLimitConnections, err := client.GetHTTPConnectionsLimitWithFields(initialCtx, options.fields["http/limit_conns"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get HTTPLimitConnections: %w", err)
 		}
@@ -1534,8 +1951,8 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil
 	})
 
-	initialGroup.Go(func() error {
-		workers, err := client.GetWorkers(initialCtx)
+	fetch(initialGroup, "workers", func() error {
+		workers, err := client.GetWorkersWithFields(initialCtx, options.fields["workers"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get Workers: %w", err)
 		}
@@ -1552,85 +1969,60 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 	}
 
 	// Process stream endpoints if they exist
-	if slices.Contains(stats.endpoints, "stream") {
-		availableStreamGroup, asgCtx := errgroup.WithContext(ctx)
+	if slices.Contains(endpoints, "stream") {
+		streamGroup, sgCtx := errgroup.WithContext(ctx)
 
-		availableStreamGroup.Go(func() error {
-			streamEndpoints, err := client.GetAvailableStreamEndpoints(asgCtx)
+		fetch(streamGroup, "stream/server_zones", func() error {
+			streamServerZones, err := client.GetStreamServerZonesWithFields(sgCtx, options.fields["stream/server_zones"]...)
 			if err != nil {
-				return fmt.Errorf("failed to get available Stream Endpoints: %w", err)
+				return fmt.Errorf("failed to get streamServerZones: %w", err)
 			}
 
 			mu.Lock()
-			stats.streamEndpoints = streamEndpoints
+			stats.StreamServerZones = *streamServerZones
 			mu.Unlock()
 
 			return nil
 		})
 
-		if err := availableStreamGroup.Wait(); err != nil {
-			return nil, fmt.Errorf("no useful metrics found in stream stats: %w", err)
-		}
-
-		streamGroup, sgCtx := errgroup.WithContext(ctx)
-
-		if slices.Contains(stats.streamEndpoints, "server_zones") {
-			streamGroup.Go(func() error {
-				streamServerZones, err := client.GetStreamServerZones(sgCtx)
-				if err != nil {
-					return fmt.Errorf("failed to get streamServerZones: %w", err)
-				}
-
-				mu.Lock()
-				stats.StreamServerZones = *streamServerZones
-				mu.Unlock()
-
-				return nil
-			})
-		}
-
-		if slices.Contains(stats.streamEndpoints, "upstreams") {
-			streamGroup.Go(func() error {
-				streamUpstreams, err := client.GetStreamUpstreams(sgCtx)
-				if err != nil {
-					return fmt.Errorf("failed to get StreamUpstreams: %w", err)
-				}
+		fetch(streamGroup, "stream/upstreams", func() error {
+			streamUpstreams, err := client.GetStreamUpstreamsWithFields(sgCtx, options.fields["stream/upstreams"]...)
+			if err != nil {
+				return fmt.Errorf("failed to get StreamUpstreams: %w", err)
+			}
 
-				mu.Lock()
-				stats.StreamUpstreams = *streamUpstreams
-				mu.Unlock()
+			mu.Lock()
+			stats.StreamUpstreams = *streamUpstreams
+			mu.Unlock()
 
-				return nil
-			})
-		}
+			return nil
+		})
 
-		if slices.Contains(stats.streamEndpoints, "limit_conns") {
-			streamGroup.Go(func() error {
-				streamConnectionsLimit, err := client.GetStreamConnectionsLimit(sgCtx)
-				if err != nil {
-					return fmt.Errorf("failed to get StreamLimitConnections: %w", err)
-				}
+		fetch(streamGroup, "stream/limit_conns", func() error {
+			streamConnectionsLimit, err := client.GetStreamConnectionsLimitWithFields(sgCtx, options.fields["stream/limit_conns"]...)
+			if err != nil {
+				return fmt.Errorf("failed to get StreamLimitConnections: %w", err)
+			}
 
-				mu.Lock()
-				stats.StreamLimitConnections = *streamConnectionsLimit
-				mu.Unlock()
+			mu.Lock()
+			stats.StreamLimitConnections = *streamConnectionsLimit
+			mu.Unlock()
 
-				return nil
-			})
+			return nil
+		})
 
-			streamGroup.Go(func() error {
-				streamZoneSync, err := client.GetStreamZoneSync(sgCtx)
-				if err != nil {
-					return fmt.Errorf("failed to get StreamZoneSync: %w", err)
-				}
+		fetch(streamGroup, "stream/zone_sync", func() error {
+			streamZoneSync, err := client.GetStreamZoneSyncWithFields(sgCtx, options.fields["stream/zone_sync"]...)
+			if err != nil {
+				return fmt.Errorf("failed to get StreamZoneSync: %w", err)
+			}
 
-				mu.Lock()
-				stats.StreamZoneSync = streamZoneSync
-				mu.Unlock()
+			mu.Lock()
+			stats.StreamZoneSync = streamZoneSync
+			mu.Unlock()
 
-				return nil
-			})
-		}
+			return nil
+		})
 
 		if err := streamGroup.Wait(); err != nil {
 			return nil, fmt.Errorf("no useful metrics found in stream stats: %w", err)
@@ -1642,7 +2034,7 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 
 	connectionsGroup.Go(func() error {
 		// replace this call with a context specific call
-		connections, err := client.GetConnections(cgCtx)
+		connections, err := client.GetConnectionsWithFields(cgCtx, options.fields["connections"]...)
 		if err != nil {
 			return fmt.Errorf("failed to get connections: %w", err)
 		}
@@ -1658,11 +2050,30 @@ func (client *NginxClient) GetStats(ctx context.Context) (*Stats, error) {
 		return nil, fmt.Errorf("connections metrics not found: %w", err)
 	}
 
-	return &stats.Stats, nil
+	return stats, nil
+}
+
+// statsEndpoints returns the root and stream endpoints of the API, from the cached capabilities if they are recent.
+func (client *NginxClient) statsEndpoints(ctx context.Context) (endpoints []string, streamEndpoints []string, err error) {
+	if capabilities := client.cachedCapabilities(); capabilities != nil {
+		return capabilities.Endpoints, capabilities.StreamEndpoints, nil
+	}
+	endpoints, err = client.GetAvailableEndpoints(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get available Endpoints: %w", err)
+	}
+	if slices.Contains(endpoints, "stream") {
+		streamEndpoints, err = client.GetAvailableStreamEndpoints(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get available Stream Endpoints: %w", err)
+		}
+	}
+	return endpoints, streamEndpoints, nil
 }
 
 // GetAvailableEndpoints returns available endpoints in the API.
 func (client *NginxClient) GetAvailableEndpoints(ctx context.Context) ([]string, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetAvailableEndpoints"})
 	var endpoints []string
 	err := client.get(ctx, "", &endpoints)
 	if err != nil {
@@ -1673,6 +2084,7 @@ func (client *NginxClient) GetAvailableEndpoints(ctx context.Context) ([]string,
 
 // GetAvailableStreamEndpoints returns available stream endpoints in the API with a context.
 func (client *NginxClient) GetAvailableStreamEndpoints(ctx context.Context) ([]string, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetAvailableStreamEndpoints"})
 	var endpoints []string
 	err := client.get(ctx, "stream", &endpoints)
 	if err != nil {
@@ -1683,8 +2095,16 @@ func (client *NginxClient) GetAvailableStreamEndpoints(ctx context.Context) ([]s
 
 // GetNginxInfo returns Nginx stats with a context.
 func (client *NginxClient) GetNginxInfo(ctx context.Context) (*NginxInfo, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetNginxInfo"})
+	return client.GetNginxInfoWithFields(ctx)
+}
+
+// GetNginxInfoWithFields returns Nginx stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetNginxInfoWithFields(ctx context.Context, fields ...string) (*NginxInfo, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetNginxInfoWithFields"})
 	var info NginxInfo
-	err := client.get(ctx, "nginx", &info)
+	err := client.getWithFields(ctx, "nginx", fields, &info)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get info: %w", err)
 	}
@@ -1693,18 +2113,14 @@ func (client *NginxClient) GetNginxInfo(ctx context.Context) (*NginxInfo, error)
 
 // GetNginxLicense returns Nginx License data with a context.
 func (client *NginxClient) GetNginxLicense(ctx context.Context) (*NginxLicense, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetNginxLicense"})
 	var data NginxLicense
 
-	info, err := client.GetNginxInfo(ctx)
+	capabilities, err := client.Capabilities(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get nginx info: %w", err)
-	}
-	release, err := extractPlusVersionValues(info.Build)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get nginx plus release: %w", err)
+		return nil, fmt.Errorf("failed to get license: %w", err)
 	}
-
-	if (client.apiVersion < 9) || (release < 33) {
+	if capabilities.Supports("license") != nil {
 		return &data, nil
 	}
 
@@ -1717,8 +2133,16 @@ func (client *NginxClient) GetNginxLicense(ctx context.Context) (*NginxLicense,
 
 // GetCaches returns Cache stats with a context.
 func (client *NginxClient) GetCaches(ctx context.Context) (*Caches, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetCaches"})
+	return client.GetCachesWithFields(ctx)
+}
+
+// GetCachesWithFields returns Cache stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetCachesWithFields(ctx context.Context, fields ...string) (*Caches, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetCachesWithFields"})
 	var caches Caches
-	err := client.get(ctx, "http/caches", &caches)
+	err := client.getWithFields(ctx, "http/caches", fields, &caches)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get caches: %w", err)
 	}
@@ -1727,8 +2151,16 @@ func (client *NginxClient) GetCaches(ctx context.Context) (*Caches, error) {
 
 // GetSlabs returns Slabs stats with a context.
 func (client *NginxClient) GetSlabs(ctx context.Context) (*Slabs, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSlabs"})
+	return client.GetSlabsWithFields(ctx)
+}
+
+// GetSlabsWithFields returns Slabs stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetSlabsWithFields(ctx context.Context, fields ...string) (*Slabs, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSlabsWithFields"})
 	var slabs Slabs
-	err := client.get(ctx, "slabs", &slabs)
+	err := client.getWithFields(ctx, "slabs", fields, &slabs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get slabs: %w", err)
 	}
@@ -1737,8 +2169,16 @@ func (client *NginxClient) GetSlabs(ctx context.Context) (*Slabs, error) {
 
 // GetConnections returns Connections stats with a context.
 func (client *NginxClient) GetConnections(ctx context.Context) (*Connections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetConnections"})
+	return client.GetConnectionsWithFields(ctx)
+}
+
+// GetConnectionsWithFields returns Connections stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetConnectionsWithFields(ctx context.Context, fields ...string) (*Connections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetConnectionsWithFields"})
 	var cons Connections
-	err := client.get(ctx, "connections", &cons)
+	err := client.getWithFields(ctx, "connections", fields, &cons)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connections: %w", err)
 	}
@@ -1747,8 +2187,16 @@ func (client *NginxClient) GetConnections(ctx context.Context) (*Connections, er
 
 // GetHTTPRequests returns http/requests stats with a context.
 func (client *NginxClient) GetHTTPRequests(ctx context.Context) (*HTTPRequests, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPRequests"})
+	return client.GetHTTPRequestsWithFields(ctx)
+}
+
+// GetHTTPRequestsWithFields returns http/requests stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetHTTPRequestsWithFields(ctx context.Context, fields ...string) (*HTTPRequests, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPRequestsWithFields"})
 	var requests HTTPRequests
-	err := client.get(ctx, "http/requests", &requests)
+	err := client.getWithFields(ctx, "http/requests", fields, &requests)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get http requests: %w", err)
 	}
@@ -1757,8 +2205,16 @@ func (client *NginxClient) GetHTTPRequests(ctx context.Context) (*HTTPRequests,
 
 // GetSSL returns SSL stats with a context.
 func (client *NginxClient) GetSSL(ctx context.Context) (*SSL, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSSL"})
+	return client.GetSSLWithFields(ctx)
+}
+
+// GetSSLWithFields returns SSL stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetSSLWithFields(ctx context.Context, fields ...string) (*SSL, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSSLWithFields"})
 	var ssl SSL
-	err := client.get(ctx, "ssl", &ssl)
+	err := client.getWithFields(ctx, "ssl", fields, &ssl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ssl: %w", err)
 	}
@@ -1767,8 +2223,16 @@ func (client *NginxClient) GetSSL(ctx context.Context) (*SSL, error) {
 
 // GetServerZones returns http/server_zones stats with a context.
 func (client *NginxClient) GetServerZones(ctx context.Context) (*ServerZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetServerZones"})
+	return client.GetServerZonesWithFields(ctx)
+}
+
+// GetServerZonesWithFields returns http/server_zones stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetServerZonesWithFields(ctx context.Context, fields ...string) (*ServerZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetServerZonesWithFields"})
 	var zones ServerZones
-	err := client.get(ctx, "http/server_zones", &zones)
+	err := client.getWithFields(ctx, "http/server_zones", fields, &zones)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get server zones: %w", err)
 	}
@@ -1777,14 +2241,21 @@ func (client *NginxClient) GetServerZones(ctx context.Context) (*ServerZones, er
 
 // GetStreamServerZones returns stream/server_zones stats with a context.
 func (client *NginxClient) GetStreamServerZones(ctx context.Context) (*StreamServerZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServerZones"})
+	return client.GetStreamServerZonesWithFields(ctx)
+}
+
+// GetStreamServerZonesWithFields returns stream/server_zones stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamServerZonesWithFields(ctx context.Context, fields ...string) (*StreamServerZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServerZonesWithFields"})
+	// The stream API is missing when the configuration has no stream block.
+	ctx = expectingErrors(ctx, ErrPathNotFound)
 	var zones StreamServerZones
-	err := client.get(ctx, "stream/server_zones", &zones)
+	err := client.getWithFields(ctx, "stream/server_zones", fields, &zones)
 	if err != nil {
-		var ie *internalError
-		if errors.As(err, &ie) {
-			if ie.Code == pathNotFoundCode {
-				return &zones, nil
-			}
+		if errors.Is(err, ErrPathNotFound) {
+			return &zones, nil
 		}
 		return nil, fmt.Errorf("failed to get stream server zones: %w", err)
 	}
@@ -1793,8 +2264,16 @@ func (client *NginxClient) GetStreamServerZones(ctx context.Context) (*StreamSer
 
 // GetUpstreams returns http/upstreams stats with a context.
 func (client *NginxClient) GetUpstreams(ctx context.Context) (*Upstreams, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetUpstreams"})
+	return client.GetUpstreamsWithFields(ctx)
+}
+
+// GetUpstreamsWithFields returns http/upstreams stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetUpstreamsWithFields(ctx context.Context, fields ...string) (*Upstreams, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetUpstreamsWithFields"})
 	var upstreams Upstreams
-	err := client.get(ctx, "http/upstreams", &upstreams)
+	err := client.getWithFields(ctx, "http/upstreams", fields, &upstreams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get upstreams: %w", err)
 	}
@@ -1803,14 +2282,21 @@ func (client *NginxClient) GetUpstreams(ctx context.Context) (*Upstreams, error)
 
 // GetStreamUpstreams returns stream/upstreams stats with a context.
 func (client *NginxClient) GetStreamUpstreams(ctx context.Context) (*StreamUpstreams, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamUpstreams"})
+	return client.GetStreamUpstreamsWithFields(ctx)
+}
+
+// GetStreamUpstreamsWithFields returns stream/upstreams stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamUpstreamsWithFields(ctx context.Context, fields ...string) (*StreamUpstreams, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamUpstreamsWithFields"})
+	// The stream API is missing when the configuration has no stream block.
+	ctx = expectingErrors(ctx, ErrPathNotFound)
 	var upstreams StreamUpstreams
-	err := client.get(ctx, "stream/upstreams", &upstreams)
+	err := client.getWithFields(ctx, "stream/upstreams", fields, &upstreams)
 	if err != nil {
-		var ie *internalError
-		if errors.As(err, &ie) {
-			if ie.Code == pathNotFoundCode {
-				return &upstreams, nil
-			}
+		if errors.Is(err, ErrPathNotFound) {
+			return &upstreams, nil
 		}
 		return nil, fmt.Errorf("failed to get stream upstreams: %w", err)
 	}
@@ -1819,14 +2305,21 @@ func (client *NginxClient) GetStreamUpstreams(ctx context.Context) (*StreamUpstr
 
 // GetStreamZoneSync returns stream/zone_sync stats with a context.
 func (client *NginxClient) GetStreamZoneSync(ctx context.Context) (*StreamZoneSync, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamZoneSync"})
+	return client.GetStreamZoneSyncWithFields(ctx)
+}
+
+// GetStreamZoneSyncWithFields returns stream/zone_sync stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamZoneSyncWithFields(ctx context.Context, fields ...string) (*StreamZoneSync, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamZoneSyncWithFields"})
+	// The stream API is missing when the configuration has no stream block.
+	ctx = expectingErrors(ctx, ErrPathNotFound)
 	var streamZoneSync StreamZoneSync
-	err := client.get(ctx, "stream/zone_sync", &streamZoneSync)
+	err := client.getWithFields(ctx, "stream/zone_sync", fields, &streamZoneSync)
 	if err != nil {
-		var ie *internalError
-		if errors.As(err, &ie) {
-			if ie.Code == pathNotFoundCode {
-				return nil, nil
-			}
+		if errors.Is(err, ErrPathNotFound) {
+			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get stream zone sync: %w", err)
 	}
@@ -1836,11 +2329,19 @@ func (client *NginxClient) GetStreamZoneSync(ctx context.Context) (*StreamZoneSy
 
 // GetLocationZones returns http/location_zones stats with a context.
 func (client *NginxClient) GetLocationZones(ctx context.Context) (*LocationZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetLocationZones"})
+	return client.GetLocationZonesWithFields(ctx)
+}
+
+// GetLocationZonesWithFields returns http/location_zones stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetLocationZonesWithFields(ctx context.Context, fields ...string) (*LocationZones, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetLocationZonesWithFields"})
 	var locationZones LocationZones
-	if client.apiVersion < 5 {
+	if checkAPIVersion("http/location_zones", client.apiVersion) != nil {
 		return &locationZones, nil
 	}
-	err := client.get(ctx, "http/location_zones", &locationZones)
+	err := client.getWithFields(ctx, "http/location_zones", fields, &locationZones)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get location zones: %w", err)
 	}
@@ -1850,11 +2351,19 @@ func (client *NginxClient) GetLocationZones(ctx context.Context) (*LocationZones
 
 // GetResolvers returns Resolvers stats with a context.
 func (client *NginxClient) GetResolvers(ctx context.Context) (*Resolvers, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetResolvers"})
+	return client.GetResolversWithFields(ctx)
+}
+
+// GetResolversWithFields returns Resolvers stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetResolversWithFields(ctx context.Context, fields ...string) (*Resolvers, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetResolversWithFields"})
 	var resolvers Resolvers
-	if client.apiVersion < 5 {
+	if checkAPIVersion("resolvers", client.apiVersion) != nil {
 		return &resolvers, nil
 	}
-	err := client.get(ctx, "resolvers", &resolvers)
+	err := client.getWithFields(ctx, "resolvers", fields, &resolvers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resolvers: %w", err)
 	}
@@ -1864,8 +2373,16 @@ func (client *NginxClient) GetResolvers(ctx context.Context) (*Resolvers, error)
 
 // GetProcesses returns Processes stats with a context.
 func (client *NginxClient) GetProcesses(ctx context.Context) (*Processes, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetProcesses"})
+	return client.GetProcessesWithFields(ctx)
+}
+
+// GetProcessesWithFields returns Processes stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetProcessesWithFields(ctx context.Context, fields ...string) (*Processes, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetProcessesWithFields"})
 	var processes Processes
-	err := client.get(ctx, "processes", &processes)
+	err := client.getWithFields(ctx, "processes", fields, &processes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get processes: %w", err)
 	}
@@ -1881,11 +2398,13 @@ type KeyValPairsByZone map[string]KeyValPairs
 
 // GetKeyValPairs fetches key/value pairs for a given HTTP zone.
 func (client *NginxClient) GetKeyValPairs(ctx context.Context, zone string) (KeyValPairs, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetKeyValPairs", Zone: zone})
 	return client.getKeyValPairs(ctx, zone, httpContext)
 }
 
 // GetStreamKeyValPairs fetches key/value pairs for a given Stream zone.
 func (client *NginxClient) GetStreamKeyValPairs(ctx context.Context, zone string) (KeyValPairs, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamKeyValPairs", Zone: zone})
 	return client.getKeyValPairs(ctx, zone, streamContext)
 }
 
@@ -1909,11 +2428,13 @@ func (client *NginxClient) getKeyValPairs(ctx context.Context, zone string, stre
 
 // GetAllKeyValPairs fetches all key/value pairs for all HTTP zones.
 func (client *NginxClient) GetAllKeyValPairs(ctx context.Context) (KeyValPairsByZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetAllKeyValPairs"})
 	return client.getAllKeyValPairs(ctx, httpContext)
 }
 
 // GetAllStreamKeyValPairs fetches all key/value pairs for all Stream zones.
 func (client *NginxClient) GetAllStreamKeyValPairs(ctx context.Context) (KeyValPairsByZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetAllStreamKeyValPairs"})
 	return client.getAllKeyValPairs(ctx, streamContext)
 }
 
@@ -1934,11 +2455,13 @@ func (client *NginxClient) getAllKeyValPairs(ctx context.Context, stream bool) (
 
 // AddKeyValPair adds a new key/value pair to a given HTTP zone.
 func (client *NginxClient) AddKeyValPair(ctx context.Context, zone string, key string, val string) error {
+	ctx = withOperation(ctx, Operation{Name: "AddKeyValPair", Zone: zone})
 	return client.addKeyValPair(ctx, zone, key, val, httpContext)
 }
 
 // AddStreamKeyValPair adds a new key/value pair to a given Stream zone.
 func (client *NginxClient) AddStreamKeyValPair(ctx context.Context, zone string, key string, val string) error {
+	ctx = withOperation(ctx, Operation{Name: "AddStreamKeyValPair", Zone: zone})
 	return client.addKeyValPair(ctx, zone, key, val, streamContext)
 }
 
@@ -1953,7 +2476,7 @@ func (client *NginxClient) addKeyValPair(ctx context.Context, zone string, key s
 
 	path := fmt.Sprintf("%v/keyvals/%v", base, zone)
 	input := KeyValPairs{key: val}
-	err := client.post(ctx, path, &input)
+	err := client.post(ctx, path, &input, nil)
 	if err != nil {
 		return fmt.Errorf("failed to add key value pair for %v/%v zone: %w", base, zone, err)
 	}
@@ -1962,11 +2485,13 @@ func (client *NginxClient) addKeyValPair(ctx context.Context, zone string, key s
 
 // ModifyKeyValPair modifies the value of an existing key in a given HTTP zone.
 func (client *NginxClient) ModifyKeyValPair(ctx context.Context, zone string, key string, val string) error {
+	ctx = withOperation(ctx, Operation{Name: "ModifyKeyValPair", Zone: zone})
 	return client.modifyKeyValPair(ctx, zone, key, val, httpContext)
 }
 
 // ModifyStreamKeyValPair modifies the value of an existing key in a given Stream zone.
 func (client *NginxClient) ModifyStreamKeyValPair(ctx context.Context, zone string, key string, val string) error {
+	ctx = withOperation(ctx, Operation{Name: "ModifyStreamKeyValPair", Zone: zone})
 	return client.modifyKeyValPair(ctx, zone, key, val, streamContext)
 }
 
@@ -1990,11 +2515,13 @@ func (client *NginxClient) modifyKeyValPair(ctx context.Context, zone string, ke
 
 // DeleteKeyValuePair deletes the key/value pair for a key in a given HTTP zone.
 func (client *NginxClient) DeleteKeyValuePair(ctx context.Context, zone string, key string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteKeyValuePair", Zone: zone})
 	return client.deleteKeyValuePair(ctx, zone, key, httpContext)
 }
 
 // DeleteStreamKeyValuePair deletes the key/value pair for a key in a given Stream zone.
 func (client *NginxClient) DeleteStreamKeyValuePair(ctx context.Context, zone string, key string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteStreamKeyValuePair", Zone: zone})
 	return client.deleteKeyValuePair(ctx, zone, key, streamContext)
 }
 
@@ -2023,11 +2550,13 @@ func (client *NginxClient) deleteKeyValuePair(ctx context.Context, zone string,
 
 // DeleteKeyValPairs deletes all the key-value pairs in a given HTTP zone.
 func (client *NginxClient) DeleteKeyValPairs(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteKeyValPairs", Zone: zone})
 	return client.deleteKeyValPairs(ctx, zone, httpContext)
 }
 
 // DeleteStreamKeyValPairs deletes all the key-value pairs in a given Stream zone.
 func (client *NginxClient) DeleteStreamKeyValPairs(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "DeleteStreamKeyValPairs", Zone: zone})
 	return client.deleteKeyValPairs(ctx, zone, streamContext)
 }
 
@@ -2050,12 +2579,17 @@ func (client *NginxClient) deleteKeyValPairs(ctx context.Context, zone string, s
 
 // UpdateHTTPServer updates the server of the upstream with the matching server ID.
 func (client *NginxClient) UpdateHTTPServer(ctx context.Context, upstream string, server UpstreamServer) error {
+	ctx = withOperation(ctx, Operation{Name: "UpdateHTTPServer", Upstream: upstream})
 	path := fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, server.ID)
 	// The server ID is expected in the URI, but not expected in the body.
 	// The NGINX API will return
 	//   {"error":{"status":400,"text":"unknown parameter \"id\"","code":"UpstreamConfFormatError"}
 	// if the ID field is present.
 	server.ID = 0
+	// The fields of resolved servers are set by NGINX and cannot be changed.
+	server.Parent = nil
+	server.Host = ""
+	server.Resolve = false
 	err := client.patch(ctx, path, &server, http.StatusOK)
 	if err != nil {
 		return fmt.Errorf("failed to update %v server to %v upstream: %w", server.Server, upstream, err)
@@ -2066,12 +2600,17 @@ func (client *NginxClient) UpdateHTTPServer(ctx context.Context, upstream string
 
 // UpdateStreamServer updates the stream server of the upstream with the matching server ID.
 func (client *NginxClient) UpdateStreamServer(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+	ctx = withOperation(ctx, Operation{Name: "UpdateStreamServer", Upstream: upstream})
 	path := fmt.Sprintf("stream/upstreams/%v/servers/%v", upstream, server.ID)
 	// The server ID is expected in the URI, but not expected in the body.
 	// The NGINX API will return
 	//   {"error":{"status":400,"text":"unknown parameter \"id\"","code":"UpstreamConfFormatError"}
 	// if the ID field is present.
 	server.ID = 0
+	// The fields of resolved servers are set by NGINX and cannot be changed.
+	server.Parent = nil
+	server.Host = ""
+	server.Resolve = false
 	err := client.patch(ctx, path, &server, http.StatusOK)
 	if err != nil {
 		return fmt.Errorf("failed to update %v stream server to %v upstream: %w", server.Server, upstream, err)
@@ -2101,13 +2640,50 @@ func addPortToServer(server string) string {
 	return fmt.Sprintf("%v:%v", server, defaultServerPort)
 }
 
+// sameServer reports whether the server of NGINX is the configured server, whose port may have been added.
+// NGINX returns servers with a service, resolved from SRV records, without a port.
+func sameServer(server, serverNGX string) bool {
+	return server == serverNGX || server == addPortToServer(serverNGX)
+}
+
+// configuredServers returns the servers which are not resolved from another server.
+// The servers resolved by NGINX follow their parent, so they are not reconciled on their own.
+func configuredServers(servers []UpstreamServer) []UpstreamServer {
+	var configured []UpstreamServer
+	for _, server := range servers {
+		if server.Parent == nil {
+			configured = append(configured, server)
+		}
+	}
+	return configured
+}
+
+// configuredStreamServers returns the stream servers which are not resolved from another server.
+func configuredStreamServers(servers []StreamUpstreamServer) []StreamUpstreamServer {
+	var configured []StreamUpstreamServer
+	for _, server := range servers {
+		if server.Parent == nil {
+			configured = append(configured, server)
+		}
+	}
+	return configured
+}
+
 // GetHTTPLimitReqs returns http/limit_reqs stats with a context.
 func (client *NginxClient) GetHTTPLimitReqs(ctx context.Context) (*HTTPLimitRequests, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPLimitReqs"})
+	return client.GetHTTPLimitReqsWithFields(ctx)
+}
+
+// GetHTTPLimitReqsWithFields returns http/limit_reqs stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetHTTPLimitReqsWithFields(ctx context.Context, fields ...string) (*HTTPLimitRequests, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPLimitReqsWithFields"})
 	var limitReqs HTTPLimitRequests
-	if client.apiVersion < 6 {
+	if checkAPIVersion("http/limit_reqs", client.apiVersion) != nil {
 		return &limitReqs, nil
 	}
-	err := client.get(ctx, "http/limit_reqs", &limitReqs)
+	err := client.getWithFields(ctx, "http/limit_reqs", fields, &limitReqs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get http limit requests: %w", err)
 	}
@@ -2116,11 +2692,19 @@ func (client *NginxClient) GetHTTPLimitReqs(ctx context.Context) (*HTTPLimitRequ
 
 // GetHTTPConnectionsLimit returns http/limit_conns stats with a context.
 func (client *NginxClient) GetHTTPConnectionsLimit(ctx context.Context) (*HTTPLimitConnections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPConnectionsLimit"})
+	return client.GetHTTPConnectionsLimitWithFields(ctx)
+}
+
+// GetHTTPConnectionsLimitWithFields returns http/limit_conns stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetHTTPConnectionsLimitWithFields(ctx context.Context, fields ...string) (*HTTPLimitConnections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPConnectionsLimitWithFields"})
 	var limitConns HTTPLimitConnections
-	if client.apiVersion < 6 {
+	if checkAPIVersion("http/limit_conns", client.apiVersion) != nil {
 		return &limitConns, nil
 	}
-	err := client.get(ctx, "http/limit_conns", &limitConns)
+	err := client.getWithFields(ctx, "http/limit_conns", fields, &limitConns)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get http connections limit: %w", err)
 	}
@@ -2129,17 +2713,24 @@ func (client *NginxClient) GetHTTPConnectionsLimit(ctx context.Context) (*HTTPLi
 
 // GetStreamConnectionsLimit returns stream/limit_conns stats with a context.
 func (client *NginxClient) GetStreamConnectionsLimit(ctx context.Context) (*StreamLimitConnections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamConnectionsLimit"})
+	return client.GetStreamConnectionsLimitWithFields(ctx)
+}
+
+// GetStreamConnectionsLimitWithFields returns stream/limit_conns stats with a context.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamConnectionsLimitWithFields(ctx context.Context, fields ...string) (*StreamLimitConnections, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamConnectionsLimitWithFields"})
+	// The stream API is missing when the configuration has no stream block.
+	ctx = expectingErrors(ctx, ErrPathNotFound)
 	var limitConns StreamLimitConnections
-	if client.apiVersion < 6 {
+	if checkAPIVersion("stream/limit_conns", client.apiVersion) != nil {
 		return &limitConns, nil
 	}
-	err := client.get(ctx, "stream/limit_conns", &limitConns)
+	err := client.getWithFields(ctx, "stream/limit_conns", fields, &limitConns)
 	if err != nil {
-		var ie *internalError
-		if errors.As(err, &ie) {
-			if ie.Code == pathNotFoundCode {
-				return &limitConns, nil
-			}
+		if errors.Is(err, ErrPathNotFound) {
+			return &limitConns, nil
 		}
 		return nil, fmt.Errorf("failed to get stream connections limit: %w", err)
 	}
@@ -2148,17 +2739,400 @@ func (client *NginxClient) GetStreamConnectionsLimit(ctx context.Context) (*Stre
 
 // GetWorkers returns workers stats.
 func (client *NginxClient) GetWorkers(ctx context.Context) ([]*Workers, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetWorkers"})
+	return client.GetWorkersWithFields(ctx)
+}
+
+// GetWorkersWithFields returns workers stats.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetWorkersWithFields(ctx context.Context, fields ...string) ([]*Workers, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetWorkersWithFields"})
 	var workers []*Workers
-	if client.apiVersion < 9 {
+	if checkAPIVersion("workers", client.apiVersion) != nil {
 		return workers, nil
 	}
-	err := client.get(ctx, "workers", &workers)
+	err := client.getWithFields(ctx, "workers", fields, &workers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get workers: %w", err)
 	}
 	return workers, nil
 }
 
+// GetUpstream returns the stats of the http upstream.
+func (client *NginxClient) GetUpstream(ctx context.Context, upstream string) (*Upstream, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetUpstream", Upstream: upstream})
+	return client.GetUpstreamWithFields(ctx, upstream)
+}
+
+// GetUpstreamWithFields returns the stats of the http upstream.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetUpstreamWithFields(ctx context.Context, upstream string, fields ...string) (*Upstream, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetUpstreamWithFields", Upstream: upstream})
+	var u Upstream
+	err := client.getWithFields(ctx, fmt.Sprintf("http/upstreams/%v", upstream), fields, &u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream %v: %w", upstream, err)
+	}
+	return &u, nil
+}
+
+// GetHTTPServer returns the server of the upstream with the matching server ID.
+func (client *NginxClient) GetHTTPServer(ctx context.Context, upstream string, serverID int) (*UpstreamServer, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPServer", Upstream: upstream})
+	var server UpstreamServer
+	err := client.get(ctx, fmt.Sprintf("http/upstreams/%v/servers/%v", upstream, serverID), &server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server %v of upstream %v: %w", serverID, upstream, err)
+	}
+	return &server, nil
+}
+
+// GetServerZone returns the stats of the http server zone.
+func (client *NginxClient) GetServerZone(ctx context.Context, zone string) (*ServerZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetServerZone", Zone: zone})
+	return client.GetServerZoneWithFields(ctx, zone)
+}
+
+// GetServerZoneWithFields returns the stats of the http server zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetServerZoneWithFields(ctx context.Context, zone string, fields ...string) (*ServerZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetServerZoneWithFields", Zone: zone})
+	var serverZone ServerZone
+	err := client.getWithFields(ctx, fmt.Sprintf("http/server_zones/%v", zone), fields, &serverZone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server zone %v: %w", zone, err)
+	}
+	return &serverZone, nil
+}
+
+// GetLocationZone returns the stats of the http location zone.
+func (client *NginxClient) GetLocationZone(ctx context.Context, zone string) (*LocationZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetLocationZone", Zone: zone})
+	return client.GetLocationZoneWithFields(ctx, zone)
+}
+
+// GetLocationZoneWithFields returns the stats of the http location zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetLocationZoneWithFields(ctx context.Context, zone string, fields ...string) (*LocationZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetLocationZoneWithFields", Zone: zone})
+	var locationZone LocationZone
+	err := client.getWithFields(ctx, fmt.Sprintf("http/location_zones/%v", zone), fields, &locationZone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location zone %v: %w", zone, err)
+	}
+	return &locationZone, nil
+}
+
+// GetCache returns the stats of the http cache zone.
+func (client *NginxClient) GetCache(ctx context.Context, zone string) (*HTTPCache, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetCache", Zone: zone})
+	return client.GetCacheWithFields(ctx, zone)
+}
+
+// GetCacheWithFields returns the stats of the http cache zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetCacheWithFields(ctx context.Context, zone string, fields ...string) (*HTTPCache, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetCacheWithFields", Zone: zone})
+	var cache HTTPCache
+	err := client.getWithFields(ctx, fmt.Sprintf("http/caches/%v", zone), fields, &cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache %v: %w", zone, err)
+	}
+	return &cache, nil
+}
+
+// GetHTTPLimitReq returns the stats of the http limit_req zone.
+func (client *NginxClient) GetHTTPLimitReq(ctx context.Context, zone string) (*HTTPLimitRequest, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPLimitReq", Zone: zone})
+	return client.GetHTTPLimitReqWithFields(ctx, zone)
+}
+
+// GetHTTPLimitReqWithFields returns the stats of the http limit_req zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetHTTPLimitReqWithFields(ctx context.Context, zone string, fields ...string) (*HTTPLimitRequest, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPLimitReqWithFields", Zone: zone})
+	var limitReq HTTPLimitRequest
+	err := client.getWithFields(ctx, fmt.Sprintf("http/limit_reqs/%v", zone), fields, &limitReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get http limit requests of zone %v: %w", zone, err)
+	}
+	return &limitReq, nil
+}
+
+// GetHTTPConnectionLimit returns the stats of the http limit_conn zone.
+func (client *NginxClient) GetHTTPConnectionLimit(ctx context.Context, zone string) (*LimitConnection, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPConnectionLimit", Zone: zone})
+	return client.GetHTTPConnectionLimitWithFields(ctx, zone)
+}
+
+// GetHTTPConnectionLimitWithFields returns the stats of the http limit_conn zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetHTTPConnectionLimitWithFields(ctx context.Context, zone string, fields ...string) (*LimitConnection, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetHTTPConnectionLimitWithFields", Zone: zone})
+	var limitConn LimitConnection
+	err := client.getWithFields(ctx, fmt.Sprintf("http/limit_conns/%v", zone), fields, &limitConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get http connections limit of zone %v: %w", zone, err)
+	}
+	return &limitConn, nil
+}
+
+// GetStreamUpstream returns the stats of the stream upstream.
+func (client *NginxClient) GetStreamUpstream(ctx context.Context, upstream string) (*StreamUpstream, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamUpstream", Upstream: upstream})
+	return client.GetStreamUpstreamWithFields(ctx, upstream)
+}
+
+// GetStreamUpstreamWithFields returns the stats of the stream upstream.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamUpstreamWithFields(ctx context.Context, upstream string, fields ...string) (*StreamUpstream, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamUpstreamWithFields", Upstream: upstream})
+	var u StreamUpstream
+	err := client.getWithFields(ctx, fmt.Sprintf("stream/upstreams/%v", upstream), fields, &u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream upstream %v: %w", upstream, err)
+	}
+	return &u, nil
+}
+
+// GetStreamServer returns the stream server of the upstream with the matching server ID.
+func (client *NginxClient) GetStreamServer(ctx context.Context, upstream string, serverID int) (*StreamUpstreamServer, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServer", Upstream: upstream})
+	var server StreamUpstreamServer
+	err := client.get(ctx, fmt.Sprintf("stream/upstreams/%v/servers/%v", upstream, serverID), &server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream server %v of upstream %v: %w", serverID, upstream, err)
+	}
+	return &server, nil
+}
+
+// GetStreamServerZone returns the stats of the stream server zone.
+func (client *NginxClient) GetStreamServerZone(ctx context.Context, zone string) (*StreamServerZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServerZone", Zone: zone})
+	return client.GetStreamServerZoneWithFields(ctx, zone)
+}
+
+// GetStreamServerZoneWithFields returns the stats of the stream server zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamServerZoneWithFields(ctx context.Context, zone string, fields ...string) (*StreamServerZone, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamServerZoneWithFields", Zone: zone})
+	var serverZone StreamServerZone
+	err := client.getWithFields(ctx, fmt.Sprintf("stream/server_zones/%v", zone), fields, &serverZone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream server zone %v: %w", zone, err)
+	}
+	return &serverZone, nil
+}
+
+// GetStreamConnectionLimit returns the stats of the stream limit_conn zone.
+func (client *NginxClient) GetStreamConnectionLimit(ctx context.Context, zone string) (*LimitConnection, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamConnectionLimit", Zone: zone})
+	return client.GetStreamConnectionLimitWithFields(ctx, zone)
+}
+
+// GetStreamConnectionLimitWithFields returns the stats of the stream limit_conn zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetStreamConnectionLimitWithFields(ctx context.Context, zone string, fields ...string) (*LimitConnection, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetStreamConnectionLimitWithFields", Zone: zone})
+	var limitConn LimitConnection
+	err := client.getWithFields(ctx, fmt.Sprintf("stream/limit_conns/%v", zone), fields, &limitConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream connections limit of zone %v: %w", zone, err)
+	}
+	return &limitConn, nil
+}
+
+// GetResolver returns the stats of the resolver zone.
+func (client *NginxClient) GetResolver(ctx context.Context, zone string) (*Resolver, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetResolver", Zone: zone})
+	return client.GetResolverWithFields(ctx, zone)
+}
+
+// GetResolverWithFields returns the stats of the resolver zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetResolverWithFields(ctx context.Context, zone string, fields ...string) (*Resolver, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetResolverWithFields", Zone: zone})
+	var resolver Resolver
+	err := client.getWithFields(ctx, fmt.Sprintf("resolvers/%v", zone), fields, &resolver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resolver %v: %w", zone, err)
+	}
+	return &resolver, nil
+}
+
+// GetSlab returns the stats of the shared memory zone.
+func (client *NginxClient) GetSlab(ctx context.Context, zone string) (*Slab, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSlab", Zone: zone})
+	return client.GetSlabWithFields(ctx, zone)
+}
+
+// GetSlabWithFields returns the stats of the shared memory zone.
+// The fields, if any, limit the fields fetched from the API.
+func (client *NginxClient) GetSlabWithFields(ctx context.Context, zone string, fields ...string) (*Slab, error) {
+	ctx = withOperation(ctx, Operation{Name: "GetSlabWithFields", Zone: zone})
+	var slab Slab
+	err := client.getWithFields(ctx, fmt.Sprintf("slabs/%v", zone), fields, &slab)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slab %v: %w", zone, err)
+	}
+	return &slab, nil
+}
+
+// reset resets the statistics of the API path.
+func (client *NginxClient) reset(ctx context.Context, path string) error {
+	return client.delete(ctx, path, http.StatusNoContent)
+}
+
+// ResetConnections resets the statistics of accepted and dropped connections.
+func (client *NginxClient) ResetConnections(ctx context.Context) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetConnections"})
+	err := client.reset(ctx, "connections")
+	if err != nil {
+		return fmt.Errorf("failed to reset connections: %w", err)
+	}
+	return nil
+}
+
+// ResetSSL resets the SSL statistics.
+func (client *NginxClient) ResetSSL(ctx context.Context) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetSSL"})
+	err := client.reset(ctx, "ssl")
+	if err != nil {
+		return fmt.Errorf("failed to reset ssl: %w", err)
+	}
+	return nil
+}
+
+// ResetSlab resets the statistics of the slab allocator of the shared memory zone.
+func (client *NginxClient) ResetSlab(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetSlab", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("slabs/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset slab %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetHTTPRequests resets the statistics of client HTTP requests.
+func (client *NginxClient) ResetHTTPRequests(ctx context.Context) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetHTTPRequests"})
+	err := client.reset(ctx, "http/requests")
+	if err != nil {
+		return fmt.Errorf("failed to reset http requests: %w", err)
+	}
+	return nil
+}
+
+// ResetServerZone resets the statistics of the http server zone.
+func (client *NginxClient) ResetServerZone(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetServerZone", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("http/server_zones/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset server zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetLocationZone resets the statistics of the http location zone.
+func (client *NginxClient) ResetLocationZone(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetLocationZone", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("http/location_zones/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset location zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetCache resets the statistics of the http cache zone.
+func (client *NginxClient) ResetCache(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetCache", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("http/caches/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset cache %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetUpstream resets the statistics of every server of the http upstream and of its queue.
+func (client *NginxClient) ResetUpstream(ctx context.Context, upstream string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetUpstream", Upstream: upstream})
+	err := client.reset(ctx, fmt.Sprintf("http/upstreams/%v", upstream))
+	if err != nil {
+		return fmt.Errorf("failed to reset upstream %v: %w", upstream, err)
+	}
+	return nil
+}
+
+// ResetHTTPLimitReq resets the statistics of the http limit_req zone.
+func (client *NginxClient) ResetHTTPLimitReq(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetHTTPLimitReq", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("http/limit_reqs/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset http limit requests of zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetHTTPConnectionLimit resets the statistics of the http limit_conn zone.
+func (client *NginxClient) ResetHTTPConnectionLimit(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetHTTPConnectionLimit", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("http/limit_conns/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset http connections limit of zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetStreamServerZone resets the statistics of the stream server zone.
+func (client *NginxClient) ResetStreamServerZone(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetStreamServerZone", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("stream/server_zones/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset stream server zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetStreamUpstream resets the statistics of every server of the stream upstream.
+func (client *NginxClient) ResetStreamUpstream(ctx context.Context, upstream string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetStreamUpstream", Upstream: upstream})
+	err := client.reset(ctx, fmt.Sprintf("stream/upstreams/%v", upstream))
+	if err != nil {
+		return fmt.Errorf("failed to reset stream upstream %v: %w", upstream, err)
+	}
+	return nil
+}
+
+// ResetStreamConnectionLimit resets the statistics of the stream limit_conn zone.
+func (client *NginxClient) ResetStreamConnectionLimit(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetStreamConnectionLimit", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("stream/limit_conns/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset stream connections limit of zone %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetResolver resets the statistics of the resolver zone.
+func (client *NginxClient) ResetResolver(ctx context.Context, zone string) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetResolver", Zone: zone})
+	err := client.reset(ctx, fmt.Sprintf("resolvers/%v", zone))
+	if err != nil {
+		return fmt.Errorf("failed to reset resolver %v: %w", zone, err)
+	}
+	return nil
+}
+
+// ResetWorkers resets the statistics of all worker processes.
+func (client *NginxClient) ResetWorkers(ctx context.Context) error {
+	ctx = withOperation(ctx, Operation{Name: "ResetWorkers"})
+	err := client.reset(ctx, "workers")
+	if err != nil {
+		return fmt.Errorf("failed to reset workers: %w", err)
+	}
+	return nil
+}
+
 var rePlus = regexp.MustCompile(`-r(\d+)`)
 
 // extractPlusVersionValues.
@@ -3,9 +3,13 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -14,6 +18,7 @@ import (
 func TestDetermineUpdates(t *testing.T) {
 	t.Parallel()
 	maxConns := 1
+	parent := 1
 	tests := []struct {
 		name             string
 		updated          []UpstreamServer
@@ -160,6 +165,97 @@ func TestDetermineUpdates(t *testing.T) {
 			},
 			name: "update field and delete",
 		},
+		{
+			updated: []UpstreamServer{
+				{
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					Server: "10.0.0.5:80",
+				},
+			},
+			nginx: []UpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     3,
+					Server: "10.0.0.2:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     4,
+					Server: "10.0.0.5:80",
+				},
+			},
+			name: "no changes with resolved servers",
+		},
+		{
+			updated: []UpstreamServer{
+				{
+					Server: "10.0.0.5:80",
+				},
+			},
+			nginx: []UpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     4,
+					Server: "10.0.0.5:80",
+				},
+			},
+			expectedToDelete: []UpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+			},
+			name: "delete only the parent of resolved servers",
+		},
+		{
+			updated: []UpstreamServer{
+				{
+					Server:  "backend.example.com",
+					Service: "http",
+					Resolve: true,
+				},
+			},
+			nginx: []UpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com",
+					Service: "http",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:8080",
+					Parent: &parent,
+					Host:   "backend.example.com",
+				},
+			},
+			name: "no changes with a service",
+		},
 	}
 
 	for _, test := range tests {
@@ -176,6 +272,7 @@ func TestDetermineUpdates(t *testing.T) {
 func TestStreamDetermineUpdates(t *testing.T) {
 	t.Parallel()
 	maxConns := 1
+	parent := 1
 	tests := []struct {
 		name             string
 		updated          []StreamUpstreamServer
@@ -325,6 +422,97 @@ func TestStreamDetermineUpdates(t *testing.T) {
 			},
 			name: "update field and delete",
 		},
+		{
+			updated: []StreamUpstreamServer{
+				{
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					Server: "10.0.0.5:80",
+				},
+			},
+			nginx: []StreamUpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     3,
+					Server: "10.0.0.2:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     4,
+					Server: "10.0.0.5:80",
+				},
+			},
+			name: "no changes with resolved servers",
+		},
+		{
+			updated: []StreamUpstreamServer{
+				{
+					Server: "10.0.0.5:80",
+				},
+			},
+			nginx: []StreamUpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:80",
+					Parent: &parent,
+					Host:   "backend.example.com:80",
+				},
+				{
+					ID:     4,
+					Server: "10.0.0.5:80",
+				},
+			},
+			expectedToDelete: []StreamUpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com:80",
+					Resolve: true,
+				},
+			},
+			name: "delete only the parent of resolved servers",
+		},
+		{
+			updated: []StreamUpstreamServer{
+				{
+					Server:  "backend.example.com",
+					Service: "http",
+					Resolve: true,
+				},
+			},
+			nginx: []StreamUpstreamServer{
+				{
+					ID:      1,
+					Server:  "backend.example.com",
+					Service: "http",
+					Resolve: true,
+				},
+				{
+					ID:     2,
+					Server: "10.0.0.1:8080",
+					Parent: &parent,
+					Host:   "backend.example.com",
+				},
+			},
+			name: "no changes with a service",
+		},
 	}
 
 	for _, test := range tests {
@@ -642,6 +830,94 @@ func TestClientWithHTTPClient(t *testing.T) {
 	}
 }
 
+func TestClientWithUnixSocket(t *testing.T) {
+	t.Parallel()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api":
+			_, err := w.Write([]byte(`[4, 5, 6, 7, 8]`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		case "/api/8/http/upstreams/test/servers":
+			_, err := w.Write([]byte(`[{"id":0,"server":"127.0.0.1:80"}]`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	ts.Listener = listener
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		opts     []Option
+	}{
+		{
+			name:     "socket endpoint",
+			endpoint: "unix:" + socketPath + ":/api",
+		},
+		{
+			name:     "socket option",
+			endpoint: "http://nginx/api",
+			opts:     []Option{WithUnixSocket(socketPath)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := append([]Option{WithMaxAPIVersion(), WithCheckAPI()}, tt.opts...)
+			client, err := NewNginxClient(tt.endpoint, opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if client.apiVersion != 8 {
+				t.Fatalf("expected client.apiVersion to be 8, but got %v", client.apiVersion)
+			}
+
+			servers, err := client.GetHTTPServers(context.Background(), "test")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(servers) != 1 || servers[0].Server != "127.0.0.1:80" {
+				t.Fatalf("unexpected servers: %v", servers)
+			}
+		})
+	}
+}
+
+func TestClientWithUnixSocketErrors(t *testing.T) {
+	t.Parallel()
+	socketPath := filepath.Join(t.TempDir(), "missing.sock")
+
+	_, err := NewNginxClient("unix:"+socketPath, WithCheckAPI())
+	if err == nil {
+		t.Fatal("expected error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "unix:"+socketPath) {
+		t.Fatalf("expected error to name the socket, got %v", err)
+	}
+	if strings.Contains(err.Error(), unixSocketHost) {
+		t.Fatalf("expected error not to contain the placeholder URL, got %v", err)
+	}
+
+	_, err = NewNginxClient("unix:relative.sock")
+	if !errors.Is(err, ErrInvalidUnixSocket) {
+		t.Fatalf("expected %v, got %v", ErrInvalidUnixSocket, err)
+	}
+}
+
 func TestClientWithMaxAPI(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -1450,3 +1726,372 @@ type fakeHandler struct {
 func (h *fakeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.handler(w, r)
 }
+
+func TestGetWithFields(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	queries := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path] = r.URL.RawQuery
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/9/":
+			_, _ = w.Write([]byte(`["nginx","processes","connections","slabs","http","resolvers","ssl","workers"]`))
+		case "/9/http":
+			_, _ = w.Write([]byte(`["requests","server_zones","location_zones","caches","limit_conns","limit_reqs","upstreams","keyvals"]`))
+		case "/9/http/upstreams":
+			_, _ = w.Write([]byte(`{"test":{"peers":[{"id":0,"state":"up","active":3}]}}`))
+		case "/9/workers":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+
+	var ops []Operation
+	c, err := NewNginxClient(ts.URL, WithMiddleware(func(next RequestHandler) RequestHandler {
+		return func(op Operation, req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			ops = append(ops, op)
+			mu.Unlock()
+			return next(op, req)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	upstreams, err := c.GetUpstreamsWithFields(context.Background(), "peers", "zone")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries["/9/http/upstreams"] != "fields=peers,zone" {
+		t.Fatalf("expected fields query, got %q", queries["/9/http/upstreams"])
+	}
+	mu.Lock()
+	op := ops[len(ops)-1]
+	mu.Unlock()
+	if op.Path != "http/upstreams" || op.Name != "GetUpstreamsWithFields" {
+		t.Fatalf("expected the operation path without the query, got %+v", op)
+	}
+
+	_, err = c.GetUpstreams(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries["/9/http/upstreams"] != "" {
+		t.Fatalf("expected no fields query, got %q", queries["/9/http/upstreams"])
+	}
+	if peer := (*upstreams)["test"].Peers[0]; peer.State != "up" || peer.Active != 3 {
+		t.Fatalf("unexpected peer: %+v", peer)
+	}
+
+	_, err = c.GetStatsWithFields(context.Background(), WithStatsFields("http/upstreams", "peers"), WithStatsFields("slabs", "pages"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"/9/http/upstreams": "fields=peers",
+		"/9/slabs":          "fields=pages",
+		"/9/http/caches":    "",
+		"/9/nginx":          "",
+	}
+	for path, query := range expected {
+		if queries[path] != query {
+			t.Fatalf("expected query %q for %v, got %q", query, path, queries[path])
+		}
+	}
+
+	_, err = c.GetStatsWithFields(context.Background(), WithStatsFields("http/unknown", "peers"))
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestGetSingleObjects(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/9/http/upstreams/test":
+			_, _ = w.Write([]byte(`{"zone":"test","peers":[{"id":1,"server":"127.0.0.1:80","state":"up"}]}`))
+		case "/9/http/upstreams/test/servers/1":
+			_, _ = w.Write([]byte(`{"id":1,"server":"127.0.0.1:80","weight":2}`))
+		case "/9/stream/upstreams/stream_test":
+			_, _ = w.Write([]byte(`{"zone":"stream_test","peers":[{"id":0,"server":"127.0.0.1:8001"}]}`))
+		case "/9/http/caches/cache":
+			_, _ = w.Write([]byte(`{"size":10,"cold":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"text":"not found","code":"UpstreamNotFound"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	upstream, err := c.GetUpstream(ctx, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upstream.Zone != "test" || len(upstream.Peers) != 1 || upstream.Peers[0].State != "up" {
+		t.Fatalf("unexpected upstream: %+v", upstream)
+	}
+
+	server, err := c.GetHTTPServer(ctx, "test", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.ID != 1 || server.Server != "127.0.0.1:80" || *server.Weight != 2 {
+		t.Fatalf("unexpected server: %+v", server)
+	}
+
+	streamUpstream, err := c.GetStreamUpstream(ctx, "stream_test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streamUpstream.Zone != "stream_test" || streamUpstream.Peers[0].Server != "127.0.0.1:8001" {
+		t.Fatalf("unexpected stream upstream: %+v", streamUpstream)
+	}
+
+	cache, err := c.GetCache(ctx, "cache")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cache.Size != 10 || !cache.Cold {
+		t.Fatalf("unexpected cache: %+v", cache)
+	}
+
+	notFound := map[string]func() error{
+		"upstream": func() error {
+			_, err := c.GetUpstream(ctx, "unknown")
+			return err
+		},
+		"http server": func() error {
+			_, err := c.GetHTTPServer(ctx, "test", 2)
+			return err
+		},
+		"server zone": func() error {
+			_, err := c.GetServerZone(ctx, "unknown")
+			return err
+		},
+		"stream server": func() error {
+			_, err := c.GetStreamServer(ctx, "stream_test", 5)
+			return err
+		},
+		"resolver": func() error {
+			_, err := c.GetResolver(ctx, "unknown")
+			return err
+		},
+	}
+	for name, get := range notFound {
+		if err := get(); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%v: expected %v, got %v", name, ErrNotFound, err)
+		}
+	}
+
+	c, err = NewNginxClient(ts.URL, WithAPIVersion(5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetHTTPLimitReq(ctx, "zone"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("expected %v request, got %v", http.MethodDelete, r.Method)
+		}
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	resets := []func() error{
+		func() error { return c.ResetConnections(ctx) },
+		func() error { return c.ResetSSL(ctx) },
+		func() error { return c.ResetSlab(ctx, "zone") },
+		func() error { return c.ResetHTTPRequests(ctx) },
+		func() error { return c.ResetServerZone(ctx, "zone") },
+		func() error { return c.ResetLocationZone(ctx, "zone") },
+		func() error { return c.ResetCache(ctx, "zone") },
+		func() error { return c.ResetUpstream(ctx, "test") },
+		func() error { return c.ResetHTTPLimitReq(ctx, "zone") },
+		func() error { return c.ResetHTTPConnectionLimit(ctx, "zone") },
+		func() error { return c.ResetStreamServerZone(ctx, "zone") },
+		func() error { return c.ResetStreamUpstream(ctx, "test") },
+		func() error { return c.ResetStreamConnectionLimit(ctx, "zone") },
+		func() error { return c.ResetResolver(ctx, "zone") },
+		func() error { return c.ResetWorkers(ctx) },
+	}
+	for _, reset := range resets {
+		if err := reset(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	expected := []string{
+		"/9/connections/",
+		"/9/ssl/",
+		"/9/slabs/zone/",
+		"/9/http/requests/",
+		"/9/http/server_zones/zone/",
+		"/9/http/location_zones/zone/",
+		"/9/http/caches/zone/",
+		"/9/http/upstreams/test/",
+		"/9/http/limit_reqs/zone/",
+		"/9/http/limit_conns/zone/",
+		"/9/stream/server_zones/zone/",
+		"/9/stream/upstreams/test/",
+		"/9/stream/limit_conns/zone/",
+		"/9/resolvers/zone/",
+		"/9/workers/",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected paths %v, got %v", expected, paths)
+	}
+
+	c, err = NewNginxClient(ts.URL, WithAPIVersion(8))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.ResetWorkers(ctx); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected %v, got %v", ErrNotSupported, err)
+	}
+	if len(paths) != len(expected) {
+		t.Fatal("expected no request for an unsupported reset")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	t.Parallel()
+
+	body := `{"error":{"status":404,"text":"upstream not found","code":"UpstreamNotFound"},"request_id":"abc","href":"https://nginx.org/en/docs/http/ngx_http_api_module.html"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/9/http/upstreams/broken/servers":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`<html>bad gateway</html>`))
+		case r.Method == http.MethodGet && r.URL.Path == "/9/http/upstreams/test/servers":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(body))
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	_, _, _, err = c.UpdateHTTPServers(ctx, "test", []UpstreamServer{{Server: "127.0.0.1:80"}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	expected := &APIError{
+		Method:         http.MethodPost,
+		Path:           "http/upstreams/test/servers/",
+		Code:           "UpstreamNotFound",
+		Text:           "upstream not found",
+		RequestID:      "abc",
+		Href:           "https://nginx.org/en/docs/http/ngx_http_api_module.html",
+		Body:           []byte(body),
+		ExpectedStatus: http.StatusCreated,
+		Status:         http.StatusNotFound,
+	}
+	apiErr.message, apiErr.details = "", ""
+	if !reflect.DeepEqual(apiErr, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, apiErr)
+	}
+
+	_, err = c.GetStats(ctx)
+	if !errors.As(err, &apiErr) || apiErr.Method != http.MethodGet || apiErr.Code != "UpstreamNotFound" {
+		t.Fatalf("expected an APIError for a GET request, got %v", err)
+	}
+
+	_, err = c.GetHTTPServers(ctx, "broken")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadGateway || apiErr.Code != "" || string(apiErr.Body) != `<html>bad gateway</html>` {
+		t.Fatalf("unexpected APIError for a response without an error object: %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "expected 200 response, got 502") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+func TestAPIErrorSentinels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expected error
+		code     string
+		status   int
+	}{
+		{code: "UpstreamNotFound", status: http.StatusNotFound, expected: ErrUpstreamNotFound},
+		{code: "UpstreamServerNotFound", status: http.StatusNotFound, expected: ErrServerNotFound},
+		{code: "KeyvalNotFound", status: http.StatusNotFound, expected: ErrKeyvalNotFound},
+		{code: "KeyvalKeyNotFound", status: http.StatusNotFound, expected: ErrKeyvalKeyNotFound},
+		{code: "KeyvalKeyExists", status: http.StatusConflict, expected: ErrKeyvalKeyExists},
+		{code: "MethodDisabled", status: http.StatusMethodNotAllowed, expected: ErrMethodDisabled},
+		{code: "UpstreamStatic", status: http.StatusBadRequest, expected: ErrUpstreamStatic},
+		{code: "PathNotFound", status: http.StatusNotFound, expected: ErrPathNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"error":{"status":` + strconv.Itoa(tt.status) + `,"text":"error","code":"` + tt.code + `"}}`))
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = c.AddKeyValPair(context.Background(), "zone", "key", "val")
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+			if errors.Is(err, ErrNotFound) != (tt.status == http.StatusNotFound) {
+				t.Fatalf("expected the error to match %v only for the 404 status code, got %v", ErrNotFound, err)
+			}
+			for _, other := range tests {
+				if other.expected != tt.expected && errors.Is(err, other.expected) {
+					t.Fatalf("expected the error not to match %v, got %v", other.expected, err)
+				}
+			}
+		})
+	}
+}
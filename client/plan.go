@@ -0,0 +1,347 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// ErrPlanOutdated is returned by ApplyPlan when the servers of the upstream changed since the plan was computed.
+var ErrPlanOutdated = errors.New("upstream changed since the plan was computed")
+
+// ServersPlan is the plan of the changes to the servers of an upstream, computed by PlanHTTPServers or PlanStreamServers
+// and executed by ApplyPlan. It can be serialized to JSON, for example to be reviewed before it is applied.
+type ServersPlan struct {
+	Upstream string `json:"upstream"`
+	// Fingerprint identifies the servers of the upstream when the plan was computed.
+	Fingerprint string         `json:"fingerprint"`
+	Add         []ServerChange `json:"add,omitempty"`
+	Delete      []ServerChange `json:"delete,omitempty"`
+	Update      []ServerChange `json:"update,omitempty"`
+	Stream      bool           `json:"stream,omitempty"`
+}
+
+// IsEmpty reports whether the plan contains no changes.
+func (p *ServersPlan) IsEmpty() bool {
+	return len(p.Add) == 0 && len(p.Delete) == 0 && len(p.Update) == 0
+}
+
+// ServerChange is the change of a server in a ServersPlan.
+type ServerChange struct {
+	// HTTPServer is the server of an HTTP upstream as it is sent to the API.
+	HTTPServer *UpstreamServer `json:"http_server,omitempty"`
+	// StreamServer is the server of a stream upstream as it is sent to the API.
+	StreamServer *StreamUpstreamServer `json:"stream_server,omitempty"`
+	Server       string                `json:"server"`
+	// Fields are the parameters of the server which change. Defaults are applied to parameters which are not set.
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Resolved are the IDs of the servers resolved by NGINX from the server, which follow its changes.
+	Resolved []int `json:"resolved,omitempty"`
+	ID       int   `json:"id,omitempty"`
+}
+
+// FieldChange is the change of a parameter of a server. Before is empty for added servers and After for deleted ones.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// serverField is a parameter of a server with its value formatted for a FieldChange.
+type serverField struct {
+	name  string
+	value string
+}
+
+func (s UpstreamServer) fields() []serverField {
+	s.applyDefaults()
+	return []serverField{
+		{"weight", strconv.Itoa(*s.Weight)},
+		{"max_conns", strconv.Itoa(*s.MaxConns)},
+		{"max_fails", strconv.Itoa(*s.MaxFails)},
+		{"fail_timeout", s.FailTimeout},
+		{"slow_start", s.SlowStart},
+		{"backup", strconv.FormatBool(*s.Backup)},
+		{"down", strconv.FormatBool(*s.Down)},
+		{"drain", strconv.FormatBool(s.Drain)},
+		{"route", s.Route},
+		{"service", s.Service},
+	}
+}
+
+func (s StreamUpstreamServer) fields() []serverField {
+	s.applyDefaults()
+	return []serverField{
+		{"weight", strconv.Itoa(*s.Weight)},
+		{"max_conns", strconv.Itoa(*s.MaxConns)},
+		{"max_fails", strconv.Itoa(*s.MaxFails)},
+		{"fail_timeout", s.FailTimeout},
+		{"slow_start", s.SlowStart},
+		{"backup", strconv.FormatBool(*s.Backup)},
+		{"down", strconv.FormatBool(*s.Down)},
+		{"service", s.Service},
+	}
+}
+
+// fieldChanges returns the parameters which differ. Either list may be nil for an added or deleted server.
+func fieldChanges(before, after []serverField) []FieldChange {
+	var changes []FieldChange
+	for i := range max(len(before), len(after)) {
+		var change FieldChange
+		if i < len(before) {
+			change.Field = before[i].name
+			change.Before = before[i].value
+		}
+		if i < len(after) {
+			change.Field = after[i].name
+			change.After = after[i].value
+		}
+		if change.Before != change.After {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// fingerprint returns the fingerprint of the servers of an upstream, which does not depend on their order.
+func fingerprint[S any](servers []S, id func(S) int) (string, error) {
+	sorted := slices.Clone(servers)
+	slices.SortFunc(sorted, func(a, b S) int {
+		return cmp.Compare(id(a), id(b))
+	})
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal servers: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func httpServerID(s UpstreamServer) int { return s.ID }
+
+func streamServerID(s StreamUpstreamServer) int { return s.ID }
+
+func httpServerAddress(s UpstreamServer) string { return s.Server }
+
+func streamServerAddress(s StreamUpstreamServer) string { return s.Server }
+
+// resolvedIDs returns the IDs of the servers resolved by NGINX from the server with the ID.
+func resolvedIDs[S any](servers []S, id int, serverID func(S) int, parentID func(S) *int) []int {
+	var ids []int
+	for _, s := range servers {
+		if parent := parentID(s); parent != nil && *parent == id {
+			ids = append(ids, serverID(s))
+		}
+	}
+	return ids
+}
+
+func httpServerParent(s UpstreamServer) *int { return s.Parent }
+
+func streamServerParent(s StreamUpstreamServer) *int { return s.Parent }
+
+// PlanHTTPServers computes the changes UpdateHTTPServers would make to the servers of the upstream, without making them.
+// If there are duplicate servers with different parameters, those server entries are ignored
+// and an error is returned along with the plan.
+func (client *NginxClient) PlanHTTPServers(ctx context.Context, upstream string, servers []UpstreamServer) (*ServersPlan, error) {
+	ctx = withOperation(ctx, Operation{Name: "PlanHTTPServers", Upstream: upstream})
+	serversInNginx, err := client.GetHTTPServers(ctx, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan servers of %v upstream: %w", upstream, err)
+	}
+
+	formattedServers, err := formatServers(upstream, servers)
+
+	plan, fpErr := client.newHTTPServersPlan(upstream, formattedServers, serversInNginx)
+	if fpErr != nil {
+		return nil, fmt.Errorf("failed to plan servers of %v upstream: %w", upstream, fpErr)
+	}
+	return plan, err
+}
+
+func (client *NginxClient) newHTTPServersPlan(upstream string, servers, serversInNginx []UpstreamServer) (*ServersPlan, error) {
+	fp, err := fingerprint(configuredServers(serversInNginx), httpServerID)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ServersPlan{Upstream: upstream, Fingerprint: fp}
+
+	toAdd, toDelete, toUpdate := client.determineHTTPServerUpdates(servers, serversInNginx)
+	for _, server := range toAdd {
+		plan.Add = append(plan.Add, ServerChange{
+			Server:     server.Server,
+			Fields:     fieldChanges(nil, server.fields()),
+			HTTPServer: &server,
+		})
+	}
+	for _, server := range toDelete {
+		plan.Delete = append(plan.Delete, ServerChange{
+			Server:     server.Server,
+			ID:         server.ID,
+			Fields:     fieldChanges(server.fields(), nil),
+			Resolved:   resolvedIDs(serversInNginx, server.ID, httpServerID, httpServerParent),
+			HTTPServer: &server,
+		})
+	}
+	for _, server := range toUpdate {
+		i := slices.IndexFunc(serversInNginx, func(s UpstreamServer) bool { return s.ID == server.ID })
+		plan.Update = append(plan.Update, ServerChange{
+			Server:     server.Server,
+			ID:         server.ID,
+			Fields:     fieldChanges(serversInNginx[i].fields(), server.fields()),
+			Resolved:   resolvedIDs(serversInNginx, server.ID, httpServerID, httpServerParent),
+			HTTPServer: &server,
+		})
+	}
+	return plan, nil
+}
+
+// PlanStreamServers computes the changes UpdateStreamServers would make to the servers of the upstream, without making them.
+// If there are duplicate servers with different parameters, those server entries are ignored
+// and an error is returned along with the plan.
+func (client *NginxClient) PlanStreamServers(ctx context.Context, upstream string, servers []StreamUpstreamServer) (*ServersPlan, error) {
+	ctx = withOperation(ctx, Operation{Name: "PlanStreamServers", Upstream: upstream})
+	serversInNginx, err := client.GetStreamServers(ctx, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan stream servers of %v upstream: %w", upstream, err)
+	}
+
+	formattedServers, err := formatStreamServers(upstream, servers)
+
+	plan, fpErr := client.newStreamServersPlan(upstream, formattedServers, serversInNginx)
+	if fpErr != nil {
+		return nil, fmt.Errorf("failed to plan stream servers of %v upstream: %w", upstream, fpErr)
+	}
+	return plan, err
+}
+
+func (client *NginxClient) newStreamServersPlan(upstream string, servers, serversInNginx []StreamUpstreamServer) (*ServersPlan, error) {
+	fp, err := fingerprint(configuredStreamServers(serversInNginx), streamServerID)
+	if err != nil {
+		return nil, err
+	}
+	plan := &ServersPlan{Upstream: upstream, Fingerprint: fp, Stream: true}
+
+	toAdd, toDelete, toUpdate := client.determineStreamServerUpdates(servers, serversInNginx)
+	for _, server := range toAdd {
+		plan.Add = append(plan.Add, ServerChange{
+			Server:       server.Server,
+			Fields:       fieldChanges(nil, server.fields()),
+			StreamServer: &server,
+		})
+	}
+	for _, server := range toDelete {
+		plan.Delete = append(plan.Delete, ServerChange{
+			Server:       server.Server,
+			ID:           server.ID,
+			Fields:       fieldChanges(server.fields(), nil),
+			Resolved:     resolvedIDs(serversInNginx, server.ID, streamServerID, streamServerParent),
+			StreamServer: &server,
+		})
+	}
+	for _, server := range toUpdate {
+		i := slices.IndexFunc(serversInNginx, func(s StreamUpstreamServer) bool { return s.ID == server.ID })
+		plan.Update = append(plan.Update, ServerChange{
+			Server:       server.Server,
+			ID:           server.ID,
+			Fields:       fieldChanges(serversInNginx[i].fields(), server.fields()),
+			Resolved:     resolvedIDs(serversInNginx, server.ID, streamServerID, streamServerParent),
+			StreamServer: &server,
+		})
+	}
+	return plan, nil
+}
+
+// ApplyPlan makes the changes of the plan computed by PlanHTTPServers or PlanStreamServers.
+// It returns an error wrapping ErrPlanOutdated, without making any change, if the servers of the upstream
+// changed since the plan was computed. Otherwise, the client will attempt to make all the changes,
+// returning all the errors that occurred.
+func (client *NginxClient) ApplyPlan(ctx context.Context, plan *ServersPlan) error {
+	ctx = withOperation(ctx, Operation{Name: "ApplyPlan", Upstream: plan.Upstream})
+	err := client.checkWritable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply the plan of %v upstream: %w", plan.Upstream, err)
+	}
+
+	var fp string
+	var count int
+	if plan.Stream {
+		var servers []StreamUpstreamServer
+		servers, err = client.GetStreamServers(ctx, plan.Upstream)
+		if err == nil {
+			servers = configuredStreamServers(servers)
+			fp, err = fingerprint(servers, streamServerID)
+			count = len(servers)
+		}
+	} else {
+		var servers []UpstreamServer
+		servers, err = client.GetHTTPServers(ctx, plan.Upstream)
+		if err == nil {
+			servers = configuredServers(servers)
+			fp, err = fingerprint(servers, httpServerID)
+			count = len(servers)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply the plan of %v upstream: %w", plan.Upstream, err)
+	}
+	if fp != plan.Fingerprint {
+		return fmt.Errorf("failed to apply the plan of %v upstream: %w", plan.Upstream, ErrPlanOutdated)
+	}
+	if err := client.checkGuard(ctx, plan, count); err != nil {
+		return fmt.Errorf("failed to apply the plan of %v upstream: %w", plan.Upstream, err)
+	}
+
+	_, addErr := applyUpdates(client.updateConcurrency, plan.Add, func(change ServerChange) error {
+		return client.applyChange(ctx, plan, change, client.addHTTPServer, client.addStreamServer)
+	})
+	_, deleteErr := applyUpdates(client.updateConcurrency, plan.Delete, func(change ServerChange) error {
+		return client.applyChange(ctx, plan, change,
+			func(ctx context.Context, upstream string, server UpstreamServer) error {
+				return client.deleteHTTPServer(ctx, upstream, server.Server, change.ID)
+			},
+			func(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+				return client.deleteStreamServer(ctx, upstream, server.Server, change.ID)
+			})
+	})
+	_, updateErr := applyUpdates(client.updateConcurrency, plan.Update, func(change ServerChange) error {
+		return client.applyChange(ctx, plan, change,
+			func(ctx context.Context, upstream string, server UpstreamServer) error {
+				server.ID = change.ID
+				return client.UpdateHTTPServer(ctx, upstream, server)
+			},
+			func(ctx context.Context, upstream string, server StreamUpstreamServer) error {
+				server.ID = change.ID
+				return client.UpdateStreamServer(ctx, upstream, server)
+			})
+	})
+	err = errors.Join(addErr, deleteErr, updateErr)
+
+	if err != nil {
+		return fmt.Errorf("failed to apply the plan of %v upstream: %w", plan.Upstream, err)
+	}
+	return nil
+}
+
+// applyChange applies the change to the server of the HTTP or stream upstream of the plan.
+func (client *NginxClient) applyChange(ctx context.Context, plan *ServersPlan, change ServerChange,
+	applyHTTP func(context.Context, string, UpstreamServer) error,
+	applyStream func(context.Context, string, StreamUpstreamServer) error,
+) error {
+	if plan.Stream {
+		if change.StreamServer == nil {
+			return fmt.Errorf("stream server %v: %w", change.Server, ErrParameterRequired)
+		}
+		return applyStream(ctx, plan.Upstream, *change.StreamServer)
+	}
+	if change.HTTPServer == nil {
+		return fmt.Errorf("http server %v: %w", change.Server, ErrParameterRequired)
+	}
+	return applyHTTP(ctx, plan.Upstream, *change.HTTPServer)
+}
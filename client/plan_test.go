@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestPlanAndApplyHTTPServers(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	serversInNginx := `[{"id":1,"server":"127.0.0.1:80","weight":1},{"id":2,"server":"127.0.0.2:80","weight":1,"down":false}]`
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(serversInNginx))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	weight := 5
+	plan, err := c.PlanHTTPServers(ctx, "test", []UpstreamServer{
+		{Server: "127.0.0.1", Weight: &weight},
+		{Server: "127.0.0.3:80"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded ServersPlan
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, plan) {
+		t.Fatalf("expected the plan to be serializable, got %+v", decoded)
+	}
+
+	if len(plan.Add) != 1 || plan.Add[0].Server != "127.0.0.3:80" {
+		t.Fatalf("unexpected additions: %+v", plan.Add)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0].Server != "127.0.0.2:80" || plan.Delete[0].ID != 2 {
+		t.Fatalf("unexpected deletions: %+v", plan.Delete)
+	}
+	expectedFields := []FieldChange{{Field: "weight", Before: "1", After: "5"}}
+	if len(plan.Update) != 1 || plan.Update[0].ID != 1 || !reflect.DeepEqual(plan.Update[0].Fields, expectedFields) {
+		t.Fatalf("unexpected updates: %+v", plan.Update)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected planning to send only one request, got %v", requests)
+	}
+
+	if err := c.ApplyPlan(ctx, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedRequests := []string{
+		"GET /9/http/upstreams/test/servers ",
+		"POST /9/http/upstreams/test/servers/ " + `{"server":"127.0.0.3:80"}`,
+		"DELETE /9/http/upstreams/test/servers/2/ ",
+		"PATCH /9/http/upstreams/test/servers/1/ " + `{"weight":5,"server":"127.0.0.1:80"}`,
+	}
+	if !reflect.DeepEqual(requests[1:], expectedRequests) {
+		t.Fatalf("expected requests %v, got %v", expectedRequests, requests[1:])
+	}
+
+	mu.Lock()
+	serversInNginx = `[{"id":1,"server":"127.0.0.1:80","weight":5}]`
+	requests = nil
+	mu.Unlock()
+	err = c.ApplyPlan(ctx, plan)
+	if !errors.Is(err, ErrPlanOutdated) {
+		t.Fatalf("expected %v, got %v", ErrPlanOutdated, err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected no change for an outdated plan, got %v", requests)
+	}
+}
+
+func TestPlanStreamServers(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":1,"server":"127.0.0.1:53","max_fails":1,"down":false}]`))
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	down := true
+	plan, err := c.PlanStreamServers(context.Background(), "test", []StreamUpstreamServer{
+		{Server: "127.0.0.1:53", Down: &down},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !plan.Stream || len(plan.Add) != 0 || len(plan.Delete) != 0 || len(plan.Update) != 1 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	expectedFields := []FieldChange{{Field: "down", Before: "false", After: "true"}}
+	if !reflect.DeepEqual(plan.Update[0].Fields, expectedFields) {
+		t.Fatalf("expected field changes %+v, got %+v", expectedFields, plan.Update[0].Fields)
+	}
+	if plan.Update[0].StreamServer == nil || plan.Update[0].HTTPServer != nil {
+		t.Fatalf("expected a stream server, got %+v", plan.Update[0])
+	}
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Default values of the RetryPolicy.
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy configures how the client retries API requests that failed because of a temporary connection error,
+// such as a timeout or a connection closed by NGINX, or a 5xx response, for example while NGINX is being reloaded.
+// Other connection errors, such as TLS verification failures or refused connections, are not retried.
+// GET requests are always retried. DELETE and PATCH requests, which are idempotent in the NGINX Plus API,
+// are only retried when enabled. POST requests are never retried.
+// Zero and nil values are replaced with the defaults: 3 attempts, 100ms initial backoff, 2s max backoff, 0.2 jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. The delay doubles after every attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Jitter is the fraction, between 0 and 1, by which each delay is randomly reduced.
+	// Nil means the default jitter, while zero disables the jitter.
+	Jitter *float64
+	// RetryDelete enables retries of DELETE requests.
+	RetryDelete bool
+	// RetryPatch enables retries of PATCH requests.
+	RetryPatch bool
+}
+
+// WithRetryPolicy sets the policy used to retry failed API requests.
+// Retries never go past the deadline of the context of the request.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *NginxClient) {
+		if policy.MaxAttempts == 0 {
+			policy.MaxAttempts = defaultRetryMaxAttempts
+		}
+		if policy.InitialBackoff == 0 {
+			policy.InitialBackoff = defaultRetryInitialBackoff
+		}
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = max(defaultRetryMaxBackoff, policy.InitialBackoff)
+		}
+		if policy.Jitter == nil {
+			jitter := defaultRetryJitter
+			policy.Jitter = &jitter
+		}
+		o.retryPolicy = &policy
+	}
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be positive, got %v: %w", p.MaxAttempts, ErrInvalidRetryPolicy)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < p.InitialBackoff {
+		return fmt.Errorf("backoff must be between 0 and the max backoff, got %v and %v: %w", p.InitialBackoff, p.MaxBackoff, ErrInvalidRetryPolicy)
+	}
+	if *p.Jitter < 0 || *p.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1, got %v: %w", *p.Jitter, ErrInvalidRetryPolicy)
+	}
+	return nil
+}
+
+func (p *RetryPolicy) retries(method string) bool {
+	switch method {
+	case http.MethodGet:
+		return true
+	case http.MethodDelete:
+		return p.RetryDelete
+	case http.MethodPatch:
+		return p.RetryPatch
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxBackoff)
+	if p.Jitter == nil {
+		return delay
+	}
+	//nolint:gosec // the jitter does not need a cryptographically secure random number.
+	return delay - time.Duration(*p.Jitter*rand.Float64()*float64(delay))
+}
+
+// do sends the request using send until it succeeds, fails with a non-retryable error,
+// runs out of attempts or the next attempt would happen after the deadline of the request context.
+// The response of the last attempt is returned as is, so the caller can report the error returned by the API.
+func (p *RetryPolicy) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := send(req)
+		if !retryable(ctx, resp, err) || attempt >= p.MaxAttempts {
+			return resp, err
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		next := req
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			next = req.Clone(ctx)
+			next.Body = body
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("giving up after %v attempts: %w", attempt, errors.Join(err, ctx.Err()))
+		case <-timer.C:
+		}
+		req = next
+	}
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return temporary(err)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented
+}
+
+// temporary reports whether the connection error can go away on its own: a timeout, or a connection
+// reset or closed by NGINX, for example while it is being reloaded.
+func temporary(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
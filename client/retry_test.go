@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		policy      RetryPolicy
+		call        func(context.Context, *NginxClient) error
+		failures    int32
+		expAttempts int32
+		expErr      bool
+	}{
+		{
+			name:     "get is retried until it succeeds",
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			failures: 2,
+			call: func(ctx context.Context, c *NginxClient) error {
+				_, err := c.GetHTTPServers(ctx, "test")
+				return err
+			},
+			expAttempts: 3,
+		},
+		{
+			name:     "get fails after max attempts",
+			policy:   RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			failures: 5,
+			call: func(ctx context.Context, c *NginxClient) error {
+				_, err := c.GetHTTPServers(ctx, "test")
+				return err
+			},
+			expAttempts: 2,
+			expErr:      true,
+		},
+		{
+			name:     "post is not retried",
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			failures: 1,
+			call: func(ctx context.Context, c *NginxClient) error {
+				return c.AddKeyValPair(ctx, "zone", "key", "val")
+			},
+			expAttempts: 1,
+			expErr:      true,
+		},
+		{
+			name:     "delete is not retried by default",
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			failures: 1,
+			call: func(ctx context.Context, c *NginxClient) error {
+				return c.DeleteKeyValPairs(ctx, "zone")
+			},
+			expAttempts: 1,
+			expErr:      true,
+		},
+		{
+			name:     "delete is retried when enabled",
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryDelete: true},
+			failures: 1,
+			call: func(ctx context.Context, c *NginxClient) error {
+				return c.DeleteKeyValPairs(ctx, "zone")
+			},
+			expAttempts: 2,
+		},
+		{
+			name:     "patch is retried with its body when enabled",
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryPatch: true},
+			failures: 2,
+			call: func(ctx context.Context, c *NginxClient) error {
+				return c.ModifyKeyValPair(ctx, "zone", "key", "val")
+			},
+			expAttempts: 3,
+		},
+		{
+			name:     "retries stop at the context deadline",
+			policy:   RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second},
+			failures: 5,
+			call: func(ctx context.Context, c *NginxClient) error {
+				ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+				defer cancel()
+				_, err := c.GetHTTPServers(ctx, "test")
+				return err
+			},
+			expAttempts: 1,
+			expErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := attempts.Add(1)
+				if attempt <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = w.Write([]byte(`{"error":{"status":503,"text":"reloading","code":"Unavailable"}}`))
+					return
+				}
+				if r.Method == http.MethodPatch {
+					body := make([]byte, 1)
+					if n, _ := r.Body.Read(body); n == 0 {
+						t.Errorf("expected the retried %v request to have a body", r.Method)
+					}
+				}
+				switch r.Method {
+				case http.MethodGet:
+					_, _ = w.Write([]byte(`[]`))
+				case http.MethodDelete, http.MethodPatch:
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusCreated)
+				}
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL, WithRetryPolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = tt.call(context.Background(), c)
+			if tt.expErr && err == nil {
+				t.Fatal("expected error, but got nil")
+			}
+			if !tt.expErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if attempts.Load() != tt.expAttempts {
+				t.Fatalf("expected %v attempts, got %v", tt.expAttempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestRetryPolicyConnectionError(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// NGINX closes the connection of the first attempt, as while it is being reloaded.
+		if attempts.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = c.GetHTTPServers(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %v", attempts.Load())
+	}
+}
+
+func TestRetryPolicyPermanentConnectionError(t *testing.T) {
+	t.Parallel()
+
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{name: "connection refused", endpoint: refused.URL},
+		{name: "TLS verification failure", endpoint: untrusted.URL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewNginxClient(tt.endpoint, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Second}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			start := time.Now()
+			_, err = c.GetHTTPServers(context.Background(), "test")
+			if err == nil {
+				t.Fatal("expected error, but got nil")
+			}
+			if time.Since(start) >= time.Second {
+				t.Fatalf("expected the request not to be retried, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	t.Parallel()
+
+	jitter := 1.5
+	policies := []RetryPolicy{
+		{MaxAttempts: -1},
+		{InitialBackoff: time.Second, MaxBackoff: time.Millisecond},
+		{Jitter: &jitter},
+	}
+
+	for _, policy := range policies {
+		_, err := NewNginxClient("http://api-url", WithRetryPolicy(policy))
+		if !errors.Is(err, ErrInvalidRetryPolicy) {
+			t.Fatalf("expected %v for policy %+v, got %v", ErrInvalidRetryPolicy, policy, err)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, exp := range expected {
+		if got := policy.backoff(i + 1); got != exp {
+			t.Fatalf("retry %v: expected backoff %v, got %v", i+1, exp, got)
+		}
+	}
+
+	jitter := 0.5
+	policy.Jitter = &jitter
+	for retry := 1; retry <= 4; retry++ {
+		got := policy.backoff(retry)
+		if got > expected[retry-1] || got < expected[retry-1]/2 {
+			t.Fatalf("retry %v: expected backoff between %v and %v, got %v", retry, expected[retry-1]/2, expected[retry-1], got)
+		}
+	}
+}
+
+func TestRetryPolicyNoJitter(t *testing.T) {
+	t.Parallel()
+
+	noJitter := 0.0
+	c, err := NewNginxClient("http://api-url", WithRetryPolicy(RetryPolicy{Jitter: &noJitter}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *c.retryPolicy.Jitter != 0 {
+		t.Fatalf("expected no jitter, got %v", *c.retryPolicy.Jitter)
+	}
+	if got := c.retryPolicy.backoff(1); got != defaultRetryInitialBackoff {
+		t.Fatalf("expected backoff %v, got %v", defaultRetryInitialBackoff, got)
+	}
+
+	c, err = NewNginxClient("http://api-url", WithRetryPolicy(RetryPolicy{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *c.retryPolicy.Jitter != defaultRetryJitter {
+		t.Fatalf("expected the default jitter %v, got %v", defaultRetryJitter, *c.retryPolicy.Jitter)
+	}
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrRestartAborted is returned by RollingRestart when it stops after a host failed.
+var ErrRestartAborted = errors.New("rolling restart aborted")
+
+// Phases of a host in a rolling restart.
+const (
+	RestartPhaseDraining   = "draining"
+	RestartPhaseRestarting = "restarting"
+	RestartPhaseRecovering = "recovering"
+	RestartPhaseDone       = "done"
+	RestartPhaseFailed     = "failed"
+)
+
+// RollingRestart configures the restart of backend hosts, one group of hosts after the other, by RollingRestart.
+type RollingRestart struct {
+	// Restart restarts or deploys the host, once its servers are drained. It is required.
+	Restart func(ctx context.Context, host HostMatcher) error
+	// Progress, if not nil, is called when a host enters a phase.
+	Progress func(RestartProgress)
+	// Hosts are the hosts to restart, in order.
+	Hosts []HostMatcher
+	// MaxUnavailable is the maximum number of hosts restarted at the same time. It defaults to 1.
+	MaxUnavailable int
+	// DrainTimeout is how long to wait for the active connections of the servers of a host to reach zero.
+	// The host is restarted once the timeout elapses. Zero means no timeout, only the context limits the wait.
+	DrainTimeout time.Duration
+	// HealthyTimeout is how long to wait for the servers of a host to be up and pass their health checks after the restart.
+	// The host fails if the timeout elapses. Zero means no timeout, only the context limits the wait.
+	HealthyTimeout time.Duration
+	// PollInterval is how often the peers of a host are polled. It defaults to one second.
+	PollInterval time.Duration
+	// AbortOnFailure stops the rolling restart after the group of hosts in which a host failed.
+	AbortOnFailure bool
+}
+
+// RestartProgress describes a host entering a phase of a rolling restart.
+type RestartProgress struct {
+	// Err is the error of the host in the failed phase.
+	Err   error
+	Host  HostMatcher
+	Phase string
+}
+
+// RestartResult is the result of the restart of a host.
+type RestartResult struct {
+	// Err is the error of the host, or nil if it was restarted and is healthy.
+	Err error
+	// Maintenance is the record of the servers of the host taken out of the upstreams. If the restart of the host
+	// failed, its servers are left out of the upstreams, and EndMaintenance puts them back.
+	Maintenance *Maintenance
+	Host        HostMatcher
+	// Duration is how long the restart of the host took.
+	Duration time.Duration
+}
+
+// RollingRestart restarts the hosts in order, restarting at most MaxUnavailable hosts at the same time.
+// For every host, the servers of the host are set to drain in all the HTTP and stream upstreams, like StartMaintenance,
+// then the restart waits for their active connections to reach zero, calls the Restart function, puts the servers back,
+// like EndMaintenance, and waits for their peers to be up and, if they have health checks, to have passed the last one.
+// The next group of hosts is restarted once all the hosts of the group are done.
+//
+// A host without servers in the upstreams fails with an error wrapping ErrServerNotFound, without being restarted.
+// If draining a host fails, its servers are put back. If the Restart function fails, the servers of the host are left out.
+// The results of the restarted hosts are returned with the joined errors of the failed hosts.
+// With AbortOnFailure, the error also wraps ErrRestartAborted and the remaining hosts are not restarted.
+func (client *NginxClient) RollingRestart(ctx context.Context, restart RollingRestart) ([]RestartResult, error) {
+	ctx = withOperation(ctx, Operation{Name: "RollingRestart"})
+	if restart.Restart == nil {
+		return nil, fmt.Errorf("failed to restart hosts: restart function: %w", ErrParameterRequired)
+	}
+	maxUnavailable := max(restart.MaxUnavailable, 1)
+
+	var results []RestartResult
+	var err error
+	for start := 0; start < len(restart.Hosts); start += maxUnavailable {
+		hosts := restart.Hosts[start:min(start+maxUnavailable, len(restart.Hosts))]
+		group := make([]RestartResult, len(hosts))
+		var g errgroup.Group
+		for i, host := range hosts {
+			g.Go(func() error {
+				group[i] = client.restartHost(ctx, host, restart)
+				return nil
+			})
+		}
+		_ = g.Wait()
+
+		failed := false
+		for _, result := range group {
+			if result.Err != nil {
+				failed = true
+				err = errors.Join(err, result.Err)
+			}
+		}
+		results = append(results, group...)
+		if failed && restart.AbortOnFailure {
+			return results, errors.Join(ErrRestartAborted, err)
+		}
+	}
+	return results, err
+}
+
+func (restart RollingRestart) waitOptions(timeout time.Duration) WaitOptions {
+	return WaitOptions{Timeout: timeout, PollInterval: restart.PollInterval}
+}
+
+// restartHost restarts the host.
+func (client *NginxClient) restartHost(ctx context.Context, host HostMatcher, restart RollingRestart) RestartResult {
+	result := RestartResult{Host: host}
+	start := time.Now()
+	progress := func(phase string, err error) {
+		if restart.Progress != nil {
+			restart.Progress(RestartProgress{Host: host, Phase: phase, Err: err})
+		}
+	}
+	fail := func(err error) RestartResult {
+		result.Err = fmt.Errorf("failed to restart %v: %w", host.Host, err)
+		result.Duration = time.Since(start)
+		progress(RestartPhaseFailed, result.Err)
+		return result
+	}
+
+	progress(RestartPhaseDraining, nil)
+	maintenance, err := client.StartMaintenance(ctx, host, true)
+	result.Maintenance = maintenance
+	if err == nil && len(maintenance.Servers) == 0 {
+		// The host would be restarted without being taken out of the upstreams.
+		err = fmt.Errorf("servers of the host: %w", ErrServerNotFound)
+	}
+	if err == nil {
+		_, err = waitForPeers(ctx, "", PeerActiveBelow("", 1), restart.waitOptions(restart.DrainTimeout), func(ctx context.Context) ([]PeerState, error) {
+			return client.maintenancePeerStates(ctx, maintenance.Servers)
+		})
+		if errors.Is(err, ErrWaitTimeout) {
+			err = nil
+		}
+	}
+	if err != nil {
+		if maintenance != nil {
+			// The host is not restarted, so its servers are put back even if the restart is canceled.
+			err = errors.Join(err, client.EndMaintenance(context.WithoutCancel(ctx), maintenance))
+		}
+		return fail(err)
+	}
+
+	progress(RestartPhaseRestarting, nil)
+	if err := restart.Restart(ctx, host); err != nil {
+		return fail(err)
+	}
+
+	progress(RestartPhaseRecovering, nil)
+	if err := client.EndMaintenance(ctx, maintenance); err != nil {
+		return fail(err)
+	}
+	_, err = waitForPeers(ctx, "", AllPeersHealthy(), restart.waitOptions(restart.HealthyTimeout), func(ctx context.Context) ([]PeerState, error) {
+		return client.maintenancePeerStates(ctx, serversUp(maintenance.Servers))
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Duration = time.Since(start)
+	progress(RestartPhaseDone, nil)
+	return result
+}
+
+// serversUp returns the servers which were not down before the maintenance.
+func serversUp(servers []MaintenanceServer) []MaintenanceServer {
+	var up []MaintenanceServer
+	for _, server := range servers {
+		down := server.HTTPServer != nil && server.HTTPServer.Down != nil && *server.HTTPServer.Down
+		down = down || server.StreamServer != nil && server.StreamServer.Down != nil && *server.StreamServer.Down
+		if !down {
+			up = append(up, server)
+		}
+	}
+	return up
+}
+
+// maintenancePeerStates returns the states of the peers of the servers, in the order of the servers.
+// A server with resolve or service has no peer of its own, so the states of the peers of the servers resolved from it
+// are returned instead. The peer of a removed server has an empty state.
+func (client *NginxClient) maintenancePeerStates(ctx context.Context, servers []MaintenanceServer) ([]PeerState, error) {
+	upstreams := map[string]func(id int) ([]PeerState, error){}
+	states := make([]PeerState, 0, len(servers))
+	for _, server := range servers {
+		key := "http/" + server.Upstream
+		state := PeerState{Upstream: server.Upstream}
+		if server.Stream {
+			key = "stream/" + server.Upstream
+			state.ID, state.Server = server.StreamServer.ID, server.StreamServer.Server
+		} else {
+			state.ID, state.Server = server.HTTPServer.ID, server.HTTPServer.Server
+		}
+
+		peersOf, ok := upstreams[key]
+		if !ok {
+			var err error
+			peersOf, err = client.upstreamPeerStates(ctx, server.Upstream, server.Stream)
+			if err != nil {
+				return nil, err
+			}
+			upstreams[key] = peersOf
+		}
+		peers, err := peersOf(state.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(peers) == 0 {
+			states = append(states, state)
+			continue
+		}
+		states = append(states, peers...)
+	}
+	return states, nil
+}
+
+// upstreamPeerStates returns a function returning the states of the peers of a server of the HTTP or stream upstream.
+func (client *NginxClient) upstreamPeerStates(ctx context.Context, upstream string, stream bool) (func(id int) ([]PeerState, error), error) {
+	if stream {
+		peers, err := client.streamPeerStates(ctx, upstream)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamServerPeers(ctx, client, upstream, peers, peerStateID).peersOf, nil
+	}
+	peers, err := client.httpPeerStates(ctx, upstream)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTPServerPeers(ctx, client, upstream, peers, peerStateID).peersOf, nil
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend is a server of a fake NGINX, restarted by a rolling restart.
+type fakeBackend struct {
+	active int
+	drain  bool
+	down   bool
+}
+
+func TestRollingRestart(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		failingHost      string
+		expectedProgress []string
+		expectedDrain    map[int]bool
+		abortOnFailure   bool
+	}{
+		{
+			name: "all hosts restarted",
+			expectedProgress: []string{
+				"10.0.0.1 draining", "10.0.0.1 restarting", "10.0.0.1 recovering", "10.0.0.1 done",
+				"10.0.0.2 draining", "10.0.0.2 restarting", "10.0.0.2 recovering", "10.0.0.2 done",
+			},
+			expectedDrain: map[int]bool{0: false, 1: false},
+		},
+		{
+			name:           "aborted on failure",
+			failingHost:    "10.0.0.1",
+			abortOnFailure: true,
+			expectedProgress: []string{
+				"10.0.0.1 draining", "10.0.0.1 restarting", "10.0.0.1 failed",
+			},
+			expectedDrain: map[int]bool{0: true, 1: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			backends := map[int]*fakeBackend{0: {active: 2}, 1: {active: 1}}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case r.Method == http.MethodPatch:
+					var server UpstreamServer
+					_ = json.NewDecoder(r.Body).Decode(&server)
+					id, _ := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/9/http/upstreams/a/servers/"), "/"))
+					backends[id].drain = server.Drain
+					backends[id].down = server.Down != nil && *server.Down
+					w.WriteHeader(http.StatusOK)
+				case r.URL.Path == "/9/http/upstreams":
+					_, _ = w.Write([]byte(`{"a":{"zone":"a"}}`))
+				case r.URL.Path == "/9/stream/upstreams":
+					_, _ = w.Write([]byte(`{}`))
+				case r.URL.Path == "/9/http/upstreams/a/servers":
+					_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:80","down":false},{"id":1,"server":"10.0.0.2:80","down":false}]`))
+				case r.URL.Path == "/9/http/upstreams/a":
+					var peers []string
+					for id := range 2 {
+						b := backends[id]
+						state := "up"
+						if b.drain {
+							state = "draining"
+							b.active = max(b.active-1, 0)
+						}
+						peers = append(peers, fmt.Sprintf(`{"id":%v,"state":%q,"active":%v,"health_checks":{"checks":1,"last_passed":%v}}`,
+							id, state, b.active, !b.drain))
+					}
+					_, _ = w.Write([]byte(`{"peers":[` + strings.Join(peers, ",") + `]}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer ts.Close()
+
+			c, err := NewNginxClient(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var progress []string
+			var progressMu sync.Mutex
+			results, err := c.RollingRestart(context.Background(), RollingRestart{
+				Hosts:          []HostMatcher{{Host: "10.0.0.1"}, {Host: "10.0.0.2"}},
+				PollInterval:   time.Millisecond,
+				HealthyTimeout: time.Second,
+				AbortOnFailure: tt.abortOnFailure,
+				Restart: func(_ context.Context, host HostMatcher) error {
+					if host.Host == tt.failingHost {
+						return errors.New("deploy failed")
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					b := backends[map[string]int{"10.0.0.1": 0, "10.0.0.2": 1}[host.Host]]
+					if b.active != 0 || !b.drain {
+						t.Errorf("expected %v to be drained before the restart", host.Host)
+					}
+					return nil
+				},
+				Progress: func(p RestartProgress) {
+					progressMu.Lock()
+					defer progressMu.Unlock()
+					progress = append(progress, p.Host.Host+" "+p.Phase)
+				},
+			})
+
+			if tt.failingHost == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.failingHost != "" {
+				if !errors.Is(err, ErrRestartAborted) || len(results) != 1 || results[0].Maintenance == nil {
+					t.Fatalf("expected the restart to abort after the first host, got %v, %+v", err, results)
+				}
+			}
+			if !reflect.DeepEqual(progress, tt.expectedProgress) {
+				t.Fatalf("expected progress %v, got %v", tt.expectedProgress, progress)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for id, drain := range tt.expectedDrain {
+				if backends[id].drain != drain {
+					t.Errorf("expected server %v drain to be %v", id, drain)
+				}
+			}
+		})
+	}
+}
+
+func TestRollingRestartHostWithoutServers(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/9/http/upstreams":
+			_, _ = w.Write([]byte(`{"a":{"zone":"a"}}`))
+		case "/9/stream/upstreams":
+			_, _ = w.Write([]byte(`{}`))
+		case "/9/http/upstreams/a/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"10.0.0.1:80"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var progress []string
+	results, err := c.RollingRestart(context.Background(), RollingRestart{
+		Hosts:        []HostMatcher{{Host: "10.0.0.9"}},
+		PollInterval: time.Millisecond,
+		Restart: func(context.Context, HostMatcher) error {
+			t.Error("expected the host not to be restarted")
+			return nil
+		},
+		Progress: func(p RestartProgress) {
+			progress = append(progress, p.Phase)
+		},
+	})
+	if !errors.Is(err, ErrServerNotFound) || len(results) != 1 || !errors.Is(results[0].Err, ErrServerNotFound) {
+		t.Fatalf("expected %v, got %v, %+v", ErrServerNotFound, err, results)
+	}
+	if expected := []string{RestartPhaseDraining, RestartPhaseFailed}; !reflect.DeepEqual(progress, expected) {
+		t.Fatalf("expected progress %v, got %v", expected, progress)
+	}
+}
+
+func TestRollingRestartResolvedServer(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	parent := &fakeBackend{}
+	active := map[int]int{1: 2, 2: 1}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/9/http/upstreams/a/servers/0/":
+			var server UpstreamServer
+			_ = json.NewDecoder(r.Body).Decode(&server)
+			parent.drain = server.Drain
+			w.WriteHeader(http.StatusOK)
+		case "/9/http/upstreams":
+			_, _ = w.Write([]byte(`{"a":{"zone":"a"}}`))
+		case "/9/stream/upstreams":
+			_, _ = w.Write([]byte(`{}`))
+		case "/9/http/upstreams/a/servers":
+			_, _ = w.Write([]byte(`[{"id":0,"server":"backend.example.com:80","resolve":true},` +
+				`{"id":1,"server":"10.0.0.1:80","parent":0},{"id":2,"server":"10.0.0.2:80","parent":0}]`))
+		case "/9/http/upstreams/a":
+			// The resolved servers follow their parent.
+			var peers []string
+			for id := 1; id <= 2; id++ {
+				state := "up"
+				if parent.drain {
+					state = "draining"
+					active[id] = max(active[id]-1, 0)
+				}
+				peers = append(peers, fmt.Sprintf(`{"id":%v,"state":%q,"active":%v}`, id, state, active[id]))
+			}
+			_, _ = w.Write([]byte(`{"peers":[` + strings.Join(peers, ",") + `]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The healthy timeout is not set, so the restart would hang if the resolved servers were not found.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := c.RollingRestart(ctx, RollingRestart{
+		Hosts:        []HostMatcher{{Host: "backend.example.com"}},
+		PollInterval: time.Millisecond,
+		Restart: func(context.Context, HostMatcher) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if active[1] != 0 || active[2] != 0 || !parent.drain {
+				t.Errorf("expected the resolved servers to be drained before the restart, got %v", active)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Maintenance.Servers) != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if parent.drain {
+		t.Error("expected the server to be put back")
+	}
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// TransactionError is returned when an atomic update of the servers of an upstream fails.
+// The changes made before the failure are rolled back.
+type TransactionError struct {
+	// Err is the error which failed the update.
+	Err error
+	// RollbackErr is the error of the rollback, or nil if all the changes were rolled back.
+	RollbackErr error
+}
+
+func (e *TransactionError) Error() string {
+	if e.RollbackErr == nil {
+		return fmt.Sprintf("%v, the changes were rolled back", e.Err)
+	}
+	return fmt.Sprintf("%v, failed to roll back the changes: %v", e.Err, e.RollbackErr)
+}
+
+// Unwrap returns the error of the update and the error of the rollback, if any.
+func (e *TransactionError) Unwrap() []error {
+	if e.RollbackErr == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.RollbackErr}
+}
+
+// UpdateHTTPServersAtomically updates the servers of the upstream like UpdateHTTPServers, but all or nothing.
+// If any change fails, the changes already made are reverted: added servers are removed, removed servers
+// are added again with their original parameters, and updated servers get their original parameters back.
+// With WithDrainOnDelete, a server left draining because its removal failed gets its original parameters back too.
+// The error is then a *TransactionError, reporting the failure and the errors of the rollback, if any.
+// Duplicate servers with different parameters fail the update before any change is made.
+func (client *NginxClient) UpdateHTTPServersAtomically(ctx context.Context, upstream string, servers []UpstreamServer) (added []UpstreamServer, deleted []UpstreamServer, updated []UpstreamServer, err error) {
+	ctx = withOperation(ctx, Operation{Name: "UpdateHTTPServersAtomically", Upstream: upstream})
+	err = client.checkWritable(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, err)
+	}
+	snapshot, err := client.GetHTTPServers(ctx, upstream)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, err)
+	}
+	formattedServers, err := formatServers(upstream, servers)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, err)
+	}
+
+	toAdd, toDelete, toUpdate := client.determineHTTPServerUpdates(formattedServers, snapshot)
+	client.logUpdates(ctx, upstream, upstreamServerAddresses(toAdd), upstreamServerAddresses(toDelete), upstreamServerAddresses(toUpdate))
+
+	if client.guard != nil {
+		plan, guardErr := client.newHTTPServersPlan(upstream, formattedServers, snapshot)
+		if guardErr == nil {
+			guardErr = client.checkGuard(ctx, plan, len(configuredServers(snapshot)))
+		}
+		if guardErr != nil {
+			return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, guardErr)
+		}
+	}
+
+	added, deleted, updated, err = applyTransaction(ctx, client, httpServerChanges(client, upstream), snapshot, toAdd, toDelete, toUpdate)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update servers of %v upstream: %w", upstream, err)
+	}
+	return added, deleted, updated, nil
+}
+
+// httpServerChanges returns the changes of the servers of the HTTP upstream made by a transaction.
+func httpServerChanges(client *NginxClient, upstream string) serverChanges[UpstreamServer] {
+	changes := serverChanges[UpstreamServer]{
+		add: func(ctx context.Context, server UpstreamServer) (int, error) {
+			// The servers removed by the transaction are added again without their ID.
+			server.ID = 0
+			return client.createHTTPServer(ctx, upstream, server)
+		},
+		removeAdded: func(ctx context.Context, server UpstreamServer, id int) error {
+			return client.removeAddedHTTPServer(ctx, upstream, server.Server, id)
+		},
+		remove: func(ctx context.Context, server UpstreamServer) error {
+			return client.deleteHTTPServer(ctx, upstream, server.Server, server.ID)
+		},
+		update: func(ctx context.Context, server UpstreamServer) error {
+			return client.UpdateHTTPServer(ctx, upstream, server)
+		},
+		id: httpServerID,
+	}
+	if client.drain != nil {
+		changes.reset = func(ctx context.Context, server UpstreamServer) error {
+			return client.resetHTTPServer(ctx, upstream, server)
+		}
+	}
+	return changes
+}
+
+// removeAddedHTTPServer removes the server added with the ID, or -1 if the API didn't return it, at once.
+// Servers added by the transaction never took traffic before it, so they are not drained.
+func (client *NginxClient) removeAddedHTTPServer(ctx context.Context, upstream, server string, serverID int) error {
+	if serverID == -1 {
+		var err error
+		serverID, err = client.getIDOfHTTPServer(ctx, upstream, server)
+		if err != nil || serverID == -1 {
+			return err
+		}
+	}
+	return client.removeHTTPServer(ctx, upstream, server, serverID)
+}
+
+// UpdateStreamServersAtomically updates the servers of the stream upstream like UpdateStreamServers, but all or nothing.
+// If any change fails, the changes already made are reverted: added servers are removed, removed servers
+// are added again with their original parameters, and updated servers get their original parameters back.
+// The error is then a *TransactionError, reporting the failure and the errors of the rollback, if any.
+// Duplicate servers with different parameters fail the update before any change is made.
+func (client *NginxClient) UpdateStreamServersAtomically(ctx context.Context, upstream string, servers []StreamUpstreamServer) (added []StreamUpstreamServer, deleted []StreamUpstreamServer, updated []StreamUpstreamServer, err error) {
+	ctx = withOperation(ctx, Operation{Name: "UpdateStreamServersAtomically", Upstream: upstream})
+	err = client.checkWritable(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, err)
+	}
+	snapshot, err := client.GetStreamServers(ctx, upstream)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, err)
+	}
+	formattedServers, err := formatStreamServers(upstream, servers)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, err)
+	}
+
+	toAdd, toDelete, toUpdate := client.determineStreamServerUpdates(formattedServers, snapshot)
+	client.logUpdates(ctx, upstream, streamUpstreamServerAddresses(toAdd), streamUpstreamServerAddresses(toDelete), streamUpstreamServerAddresses(toUpdate))
+
+	if client.guard != nil {
+		plan, guardErr := client.newStreamServersPlan(upstream, formattedServers, snapshot)
+		if guardErr == nil {
+			guardErr = client.checkGuard(ctx, plan, len(configuredStreamServers(snapshot)))
+		}
+		if guardErr != nil {
+			return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, guardErr)
+		}
+	}
+
+	added, deleted, updated, err = applyTransaction(ctx, client, streamServerChanges(client, upstream), snapshot, toAdd, toDelete, toUpdate)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update stream servers of %v upstream: %w", upstream, err)
+	}
+	return added, deleted, updated, nil
+}
+
+// streamServerChanges returns the changes of the servers of the stream upstream made by a transaction.
+func streamServerChanges(client *NginxClient, upstream string) serverChanges[StreamUpstreamServer] {
+	return serverChanges[StreamUpstreamServer]{
+		add: func(ctx context.Context, server StreamUpstreamServer) (int, error) {
+			// The servers removed by the transaction are added again without their ID.
+			server.ID = 0
+			return client.createStreamServer(ctx, upstream, server)
+		},
+		removeAdded: func(ctx context.Context, server StreamUpstreamServer, id int) error {
+			return client.removeAddedStreamServer(ctx, upstream, server.Server, id)
+		},
+		remove: func(ctx context.Context, server StreamUpstreamServer) error {
+			return client.deleteStreamServer(ctx, upstream, server.Server, server.ID)
+		},
+		update: func(ctx context.Context, server StreamUpstreamServer) error {
+			return client.UpdateStreamServer(ctx, upstream, server)
+		},
+		id: streamServerID,
+	}
+}
+
+// removeAddedStreamServer removes the stream server added with the ID, or -1 if the API didn't return it, at once.
+func (client *NginxClient) removeAddedStreamServer(ctx context.Context, upstream, server string, serverID int) error {
+	if serverID == -1 {
+		var err error
+		serverID, err = client.getIDOfStreamServer(ctx, upstream, server)
+		if err != nil || serverID == -1 {
+			return err
+		}
+	}
+	return client.deleteStreamServer(ctx, upstream, server, serverID)
+}
+
+// serverChanges are the changes of the servers of an HTTP or stream upstream made by a transaction.
+type serverChanges[S any] struct {
+	// add adds the server and returns its ID, or -1 if the API didn't return it.
+	add func(ctx context.Context, server S) (int, error)
+	// removeAdded removes the server added by the transaction with the ID.
+	removeAdded func(ctx context.Context, server S, id int) error
+	remove      func(ctx context.Context, server S) error
+	update      func(ctx context.Context, server S) error
+	// reset, if not nil, restores the parameters of a server whose removal failed, as it drains the server first.
+	reset func(ctx context.Context, server S) error
+	id    func(S) int
+}
+
+// applyTransaction makes the changes to the servers of the upstream, whose servers were the snapshot before them.
+// If any change fails, the changes already made are rolled back and the error is a *TransactionError.
+func applyTransaction[S any](ctx context.Context, client *NginxClient, changes serverChanges[S], snapshot, toAdd, toDelete, toUpdate []S) (added, deleted, updated []S, err error) {
+	tx := &transaction{}
+	added, err = applyUpdates(client.updateConcurrency, toAdd, func(server S) error {
+		id, addErr := changes.add(ctx, server)
+		if addErr == nil {
+			tx.record(func(ctx context.Context) error {
+				return changes.removeAdded(ctx, server, id)
+			})
+		}
+		return addErr
+	})
+	if err == nil {
+		deleted, err = applyUpdates(client.updateConcurrency, toDelete, func(server S) error {
+			// The undo is recorded before the removal, which can fail once the server is draining.
+			var removed bool
+			tx.record(func(ctx context.Context) error {
+				switch {
+				case removed:
+					_, addErr := changes.add(ctx, server)
+					return addErr
+				case changes.reset != nil:
+					return changes.reset(ctx, server)
+				default:
+					return nil
+				}
+			})
+			removeErr := changes.remove(ctx, server)
+			removed = removeErr == nil
+			return removeErr
+		})
+	}
+	if err == nil {
+		updated, err = applyUpdates(client.updateConcurrency, toUpdate, func(server S) error {
+			updateErr := changes.update(ctx, server)
+			if updateErr == nil {
+				i := slices.IndexFunc(snapshot, func(s S) bool { return changes.id(s) == changes.id(server) })
+				tx.record(func(ctx context.Context) error {
+					return changes.update(ctx, snapshot[i])
+				})
+			}
+			return updateErr
+		})
+	}
+	if err == nil {
+		return added, deleted, updated, nil
+	}
+	return nil, nil, nil, &TransactionError{Err: err, RollbackErr: client.rollback(ctx, tx)}
+}
+
+// transaction records how to undo the changes made to the servers of an upstream, so that they can be rolled back.
+type transaction struct {
+	undos []func(ctx context.Context) error
+	mu    sync.Mutex
+}
+
+// record records how to undo a change which was made.
+func (tx *transaction) record(undo func(ctx context.Context) error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undos = append(tx.undos, undo)
+}
+
+// rollback undoes the changes, sending at most concurrency requests at the same time.
+// When the changes are undone one at a time, the last change is undone first.
+func (tx *transaction) rollback(ctx context.Context, concurrency int) error {
+	tx.mu.Lock()
+	undos := slices.Clone(tx.undos)
+	tx.mu.Unlock()
+	slices.Reverse(undos)
+	_, err := applyUpdates(concurrency, undos, func(undo func(ctx context.Context) error) error {
+		return undo(ctx)
+	})
+	return err
+}
+
+// rollback undoes the changes recorded by the transaction. It is not canceled with the context,
+// so that the upstream is not left half changed.
+func (client *NginxClient) rollback(ctx context.Context, tx *transaction) error {
+	return tx.rollback(context.WithoutCancel(ctx), client.updateConcurrency)
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateHTTPServersAtomically(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var requests []string
+	gets := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		request := r.Method + " " + r.URL.Path + " " + string(body)
+		requests = append(requests, request)
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/9/http/upstreams/test" {
+				_, _ = w.Write([]byte(`{"peers":[{"id":1,"active":0},{"id":2,"active":0},{"id":3,"active":0}]}`))
+				return
+			}
+			gets++
+			if gets == 1 {
+				_, _ = w.Write([]byte(`[{"id":1,"server":"10.0.0.1:80","weight":2},{"id":2,"server":"10.0.0.2:80"}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"id":1,"server":"10.0.0.1:80","weight":3},{"id":2,"server":"10.0.0.2:80"},{"id":3,"server":"10.0.0.3:80"}]`))
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":3,"server":"10.0.0.3:80"}`))
+		case http.MethodPatch:
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			if r.URL.Path == "/9/http/upstreams/test/servers/2/" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"status":400,"text":"error","code":"UpstreamConfFormatError"}}`))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithDrainOnDelete(DrainOptions{PollInterval: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	weight := 3
+	added, deleted, updated, err := c.UpdateHTTPServersAtomically(context.Background(), "test", []UpstreamServer{
+		{Server: "10.0.0.1:80", Weight: &weight},
+		{Server: "10.0.0.3:80"},
+	})
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || txErr.RollbackErr != nil {
+		t.Fatalf("expected the changes to be rolled back, got %v", err)
+	}
+	if added != nil || deleted != nil || updated != nil {
+		t.Fatalf("expected no changes, got added %v, deleted %v, updated %v", added, deleted, updated)
+	}
+
+	// The server is not updated once the deletion fails. The server left draining gets its parameters back,
+	// and the added server is removed by its ID, without draining it.
+	expectedRequests := []string{
+		"GET /9/http/upstreams/test/servers ",
+		"POST /9/http/upstreams/test/servers/ " + `{"server":"10.0.0.3:80"}`,
+		"PATCH /9/http/upstreams/test/servers/2/ " + `{"drain":true}`,
+		"GET /9/http/upstreams/test ",
+		"DELETE /9/http/upstreams/test/servers/2/ ",
+		"PATCH /9/http/upstreams/test/servers/2/ " + `{"server":"10.0.0.2:80","down":false,"drain":false}`,
+		"DELETE /9/http/upstreams/test/servers/3/ ",
+	}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Fatalf("expected requests %v, got %v", expectedRequests, requests)
+	}
+
+	// Duplicate servers with different parameters fail the update before any change.
+	_, _, _, err = c.UpdateHTTPServersAtomically(context.Background(), "test", []UpstreamServer{
+		{Server: "10.0.0.1:80"}, {Server: "10.0.0.1:80", Weight: &weight},
+	})
+	if !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("expected %v, got %v", ErrParameterMismatch, err)
+	}
+	if len(requests) != len(expectedRequests)+1 {
+		t.Fatalf("expected no change for duplicate servers, got %v", requests[len(expectedRequests):])
+	}
+}
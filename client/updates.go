@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WithUpdateConcurrency sets the maximum number of requests sent concurrently by UpdateHTTPServers,
+// UpdateStreamServers and ApplyPlan to make the changes to the servers of an upstream.
+// All the servers are added before any is deleted, and deleted before any is updated.
+// By default, or if the concurrency is lower than 2, the changes are made one at a time.
+func WithUpdateConcurrency(concurrency int) Option {
+	return func(o *NginxClient) {
+		o.updateConcurrency = concurrency
+	}
+}
+
+// applyUpdates applies the change to every server, sending at most the update concurrency of the client
+// requests at the same time. It returns the servers successfully changed and the joined errors,
+// both in the order of the servers.
+func applyUpdates[S any](concurrency int, servers []S, apply func(S) error) ([]S, error) {
+	errs := make([]error, len(servers))
+	if concurrency < 2 {
+		for i, server := range servers {
+			errs[i] = apply(server)
+		}
+	} else {
+		var group errgroup.Group
+		group.SetLimit(concurrency)
+		for i, server := range servers {
+			group.Go(func() error {
+				errs[i] = apply(server)
+				return nil
+			})
+		}
+		_ = group.Wait()
+	}
+
+	var applied []S
+	for i, server := range servers {
+		if errs[i] == nil {
+			applied = append(applied, server)
+		}
+	}
+	return applied, errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateHTTPServersWithUpdateConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight, posts int
+	var deleteBeforeAdds bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`[{"id":1,"server":"127.0.0.1:80"}]`))
+			return
+		case http.MethodDelete:
+			mu.Lock()
+			deleteBeforeAdds = posts != 10
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		posts++
+		mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "10.0.0.5:80") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL, WithUpdateConcurrency(4))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var servers []UpstreamServer
+	for i := range 10 {
+		servers = append(servers, UpstreamServer{Server: fmt.Sprintf("10.0.0.%v:80", i)})
+	}
+	added, deleted, updated, err := c.UpdateHTTPServers(context.Background(), "test", servers)
+	if err == nil || !strings.Contains(err.Error(), "10.0.0.5:80") {
+		t.Fatalf("expected the error of the failed addition, got %v", err)
+	}
+	if len(added) != 9 || len(deleted) != 1 || len(updated) != 0 {
+		t.Fatalf("unexpected results: added %v, deleted %v, updated %v", added, deleted, updated)
+	}
+	for i, server := range added {
+		if server.Server == "10.0.0.5:80" || (i > 0 && server.Server < added[i-1].Server) {
+			t.Fatalf("expected the added servers in order, got %v", added)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxInFlight < 2 || maxInFlight > 4 {
+		t.Fatalf("expected between 2 and 4 concurrent requests, got %v", maxInFlight)
+	}
+	if deleteBeforeAdds {
+		t.Fatal("expected the servers to be added before deleting any")
+	}
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrWaitTimeout is wrapped by the WaitTimeoutError returned when a condition is not met before the timeout.
+var ErrWaitTimeout = errors.New("timed out waiting")
+
+// defaultWaitPollInterval is how often the peers are polled if the poll interval is not set.
+const defaultWaitPollInterval = time.Second
+
+// WaitOptions configures how WaitForHTTPPeers and WaitForStreamPeers poll the peers.
+type WaitOptions struct {
+	// Timeout is how long to wait for the condition. Zero means no timeout, only the context limits the wait.
+	Timeout time.Duration
+	// PollInterval is how often the peers are polled. It defaults to one second.
+	PollInterval time.Duration
+}
+
+// PeerState is the state of a peer of an HTTP or stream upstream, as observed while waiting.
+type PeerState struct {
+	Upstream string
+	Server   string
+	// Name is the name of the server, as configured in the upstream.
+	Name string
+	// State is the state of the peer, such as "up", "draining", "down" or "unhealthy".
+	// It is empty if the peer was not found, for example because the server was removed.
+	State        string
+	HealthChecks HealthChecks
+	Active       uint64
+	ID           int
+	Backup       bool
+}
+
+func (p PeerState) String() string {
+	state := p.State
+	if state == "" {
+		state = "not found"
+	}
+	return fmt.Sprintf("%v (id %v) %v, %v active, last health check passed %v", p.Server, p.ID, state, p.Active, p.HealthChecks.LastPassed)
+}
+
+// healthy reports whether the peer is up and, if it has health checks, passed the last one.
+func (p PeerState) healthy() bool {
+	return p.State == "up" && (p.HealthChecks.Checks == 0 || p.HealthChecks.LastPassed)
+}
+
+// PeerCondition is a condition on the peers of an upstream, for WaitForHTTPPeers and WaitForStreamPeers.
+type PeerCondition struct {
+	met         func(peers []PeerState) bool
+	description string
+}
+
+func (c PeerCondition) String() string {
+	return c.description
+}
+
+// PeerInState is the condition that the peer of the server is in one of the states, such as "up", "unhealthy" or "down".
+// The server is matched by its address or by its name, as configured in the upstream.
+func PeerInState(server string, states ...string) PeerCondition {
+	return PeerCondition{
+		description: fmt.Sprintf("%v in state %v", server, strings.Join(states, " or ")),
+		met: func(peers []PeerState) bool {
+			peer, ok := findPeer(peers, server)
+			return ok && slices.Contains(states, peer.State)
+		},
+	}
+}
+
+// PeerActiveBelow is the condition that the peer of the server has fewer than n active connections.
+// If the server is empty, all the peers must have fewer than n active connections.
+// PeerActiveBelow(server, 1) waits until the server is fully drained. The condition is not met while the peer
+// of the server is missing, so a misspelled server times out instead of passing.
+func PeerActiveBelow(server string, n uint64) PeerCondition {
+	target := server
+	if target == "" {
+		target = "all peers"
+	}
+	return PeerCondition{
+		description: fmt.Sprintf("%v with fewer than %v active connections", target, n),
+		met: func(peers []PeerState) bool {
+			if server != "" {
+				peer, ok := findPeer(peers, server)
+				return ok && peer.Active < n
+			}
+			for _, peer := range peers {
+				if peer.Active >= n {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// AllPeersHealthy is the condition that all the peers, except backup peers, are up and, if they have health checks,
+// passed the last one.
+func AllPeersHealthy() PeerCondition {
+	return PeerCondition{
+		description: "all peers healthy",
+		met: func(peers []PeerState) bool {
+			for _, peer := range peers {
+				if !peer.Backup && !peer.healthy() {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// findPeer returns the peer of the server, matched by its address or by its name.
+func findPeer(peers []PeerState, server string) (PeerState, bool) {
+	matches := func(address string) bool {
+		return address == server || sameServer(addPortToServer(server), address)
+	}
+	i := slices.IndexFunc(peers, func(p PeerState) bool {
+		return p.State != "" && (matches(p.Server) || matches(p.Name))
+	})
+	if i == -1 {
+		return PeerState{}, false
+	}
+	return peers[i], true
+}
+
+// WaitTimeoutError is returned when a condition on peers is not met before the timeout. It includes the last observed peers.
+type WaitTimeoutError struct {
+	Condition string
+	// Upstream is the upstream of the peers, or empty if the peers are of several upstreams.
+	Upstream string
+	// Peers are the peers as last observed.
+	Peers   []PeerState
+	Elapsed time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	peers := make([]string, 0, len(e.Peers))
+	for _, peer := range e.Peers {
+		peers = append(peers, peer.String())
+	}
+	upstream := ""
+	if e.Upstream != "" {
+		upstream = fmt.Sprintf(" in %v upstream", e.Upstream)
+	}
+	return fmt.Sprintf("%v for %v%v after %v, last observed peers: [%v]", ErrWaitTimeout, e.Condition, upstream, e.Elapsed.Round(time.Millisecond), strings.Join(peers, "; "))
+}
+
+// Unwrap returns ErrWaitTimeout.
+func (e *WaitTimeoutError) Unwrap() error {
+	return ErrWaitTimeout
+}
+
+// WaitForHTTPPeers polls the peers of the HTTP upstream until the condition is met, and returns them.
+// If the timeout of the options elapses first, it returns a *WaitTimeoutError with the last observed peers.
+// If the context is canceled, it returns the error of the context.
+func (client *NginxClient) WaitForHTTPPeers(ctx context.Context, upstream string, condition PeerCondition, opts WaitOptions) ([]PeerState, error) {
+	ctx = withOperation(ctx, Operation{Name: "WaitForHTTPPeers", Upstream: upstream})
+	peers, err := waitForPeers(ctx, upstream, condition, opts, func(ctx context.Context) ([]PeerState, error) {
+		return client.httpPeerStates(ctx, upstream)
+	})
+	if err != nil {
+		return peers, fmt.Errorf("failed to wait for peers of %v upstream: %w", upstream, err)
+	}
+	return peers, nil
+}
+
+// WaitForStreamPeers polls the peers of the stream upstream until the condition is met, and returns them.
+// If the timeout of the options elapses first, it returns a *WaitTimeoutError with the last observed peers.
+// If the context is canceled, it returns the error of the context.
+func (client *NginxClient) WaitForStreamPeers(ctx context.Context, upstream string, condition PeerCondition, opts WaitOptions) ([]PeerState, error) {
+	ctx = withOperation(ctx, Operation{Name: "WaitForStreamPeers", Upstream: upstream})
+	peers, err := waitForPeers(ctx, upstream, condition, opts, func(ctx context.Context) ([]PeerState, error) {
+		return client.streamPeerStates(ctx, upstream)
+	})
+	if err != nil {
+		return peers, fmt.Errorf("failed to wait for peers of %v stream upstream: %w", upstream, err)
+	}
+	return peers, nil
+}
+
+// waitForPeers polls the peers returned by fetch until the condition is met, the timeout elapses or the context is canceled.
+func waitForPeers(ctx context.Context, upstream string, condition PeerCondition, opts WaitOptions,
+	fetch func(context.Context) ([]PeerState, error),
+) ([]PeerState, error) {
+	if condition.met == nil {
+		return nil, fmt.Errorf("condition: %w", ErrParameterRequired)
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultWaitPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		peers, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if condition.met(peers) {
+			return peers, nil
+		}
+		elapsed := time.Since(start)
+		if opts.Timeout > 0 && elapsed >= opts.Timeout {
+			return peers, &WaitTimeoutError{Condition: condition.description, Upstream: upstream, Peers: peers, Elapsed: elapsed}
+		}
+
+		select {
+		case <-ctx.Done():
+			return peers, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (client *NginxClient) httpPeerStates(ctx context.Context, upstream string) ([]PeerState, error) {
+	u, err := client.GetUpstreamWithFields(ctx, upstream, "peers")
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]PeerState, 0, len(u.Peers))
+	for _, peer := range u.Peers {
+		peers = append(peers, PeerState{
+			Upstream:     upstream,
+			Server:       peer.Server,
+			Name:         peer.Name,
+			State:        peer.State,
+			HealthChecks: peer.HealthChecks,
+			Active:       peer.Active,
+			ID:           peer.ID,
+			Backup:       peer.Backup,
+		})
+	}
+	return peers, nil
+}
+
+func (client *NginxClient) streamPeerStates(ctx context.Context, upstream string) ([]PeerState, error) {
+	u, err := client.GetStreamUpstreamWithFields(ctx, upstream, "peers")
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]PeerState, 0, len(u.Peers))
+	for _, peer := range u.Peers {
+		peers = append(peers, PeerState{
+			Upstream:     upstream,
+			Server:       peer.Server,
+			Name:         peer.Name,
+			State:        peer.State,
+			HealthChecks: peer.HealthChecks,
+			Active:       peer.Active,
+			ID:           peer.ID,
+			Backup:       peer.Backup,
+		})
+	}
+	return peers, nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitForPeers(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	polls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first peer becomes up and drains its connections over the polls, the second stays unhealthy.
+		state := "unhealthy"
+		if polls >= 2 {
+			state = "up"
+		}
+		active := max(3-polls, 0)
+		polls++
+		_, _ = fmt.Fprintf(w, `{"peers":[{"id":0,"server":"10.0.0.1:80","name":"backend1.example.com:80","state":%q,"active":%v,`+
+			`"health_checks":{"checks":2,"last_passed":true}},{"id":1,"server":"10.0.0.2:80","state":"unhealthy","active":4},`+
+			`{"id":2,"server":"10.0.0.3:80","state":"down","backup":true}]}`, state, active)
+	}))
+	defer ts.Close()
+
+	c, err := NewNginxClient(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := WaitOptions{PollInterval: time.Millisecond, Timeout: time.Second}
+
+	peers, err := c.WaitForHTTPPeers(context.Background(), "test", PeerInState("backend1.example.com", "up"), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peers[0].State != "up" || peers[0].Upstream != "test" {
+		t.Fatalf("unexpected peers: %+v", peers)
+	}
+
+	_, err = c.WaitForStreamPeers(context.Background(), "test", PeerActiveBelow("10.0.0.1:80", 1), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The condition on a missing peer is not met.
+	_, err = c.WaitForHTTPPeers(context.Background(), "test", PeerActiveBelow("10.0.0.9:80", 1), WaitOptions{PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond})
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected %v, got %v", ErrWaitTimeout, err)
+	}
+
+	_, err = c.WaitForHTTPPeers(context.Background(), "test", AllPeersHealthy(), WaitOptions{PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond})
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected %v, got %v", ErrWaitTimeout, err)
+	}
+	if len(timeoutErr.Peers) != 3 || !strings.Contains(err.Error(), "10.0.0.2:80 (id 1) unhealthy, 4 active") {
+		t.Fatalf("expected the error to describe the last observed peers, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.WaitForHTTPPeers(ctx, "test", PeerInState("10.0.0.2", "up"), WaitOptions{PollInterval: time.Millisecond})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}